@@ -0,0 +1,277 @@
+package vwap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultActivityPageSize is the number of swaps requested per page of the activity feed.
+	DefaultActivityPageSize = 100
+	// DefaultActivityMaxPages bounds the pages read by a single SwapFeed run.
+	DefaultActivityMaxPages = 50
+)
+
+// SwapCursor records the newest swap processed from a feed.
+// Swaps sharing the timestamp of the newest one are told apart by their tx hash.
+type SwapCursor struct {
+	Source       string `gorm:"primaryKey;size:255"`
+	LastTime     time.Time
+	LastTxHashes string `gorm:"type:text"` // comma-separated
+	UpdatedAt    time.Time
+}
+
+// seen reports whether the swap was already processed.
+// Swaps without a tx hash at the cursor time are considered seen.
+func (c SwapCursor) seen(swap ParsedSwap) bool {
+	if c.LastTime.IsZero() {
+		return false
+	}
+	if swap.Time.Before(c.LastTime) {
+		return true
+	}
+	if swap.Time.After(c.LastTime) {
+		return false
+	}
+	if swap.TxHash == "" {
+		return true
+	}
+	for _, hash := range strings.Split(c.LastTxHashes, ",") {
+		if hash == swap.TxHash {
+			return true
+		}
+	}
+	return false
+}
+
+// advance moves the cursor past the swaps.
+func (c *SwapCursor) advance(swaps []ParsedSwap) {
+	for _, swap := range swaps {
+		switch {
+		case swap.Time.After(c.LastTime):
+			c.LastTime = swap.Time
+			c.LastTxHashes = swap.TxHash
+		case swap.Time.Equal(c.LastTime) && swap.TxHash != "":
+			if c.LastTxHashes == "" {
+				c.LastTxHashes = swap.TxHash
+			} else {
+				c.LastTxHashes += "," + swap.TxHash
+			}
+		}
+	}
+}
+
+// loadSwapCursor returns the cursor of the source, or an empty cursor if none was saved.
+func loadSwapCursor(ctx context.Context, db *gorm.DB, source string) (SwapCursor, error) {
+	var cursor SwapCursor
+	result := db.WithContext(ctx).Where("source = ?", source).First(&cursor)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return SwapCursor{Source: source}, nil
+	}
+	if result.Error != nil {
+		return SwapCursor{}, fmt.Errorf("failed to load swap cursor: %v", result.Error)
+	}
+	return cursor, nil
+}
+
+func saveSwapCursor(ctx context.Context, db *gorm.DB, cursor SwapCursor) error {
+	if err := db.WithContext(ctx).Save(&cursor).Error; err != nil {
+		return fmt.Errorf("failed to save swap cursor: %v", err)
+	}
+	return nil
+}
+
+// SwapFeed reads the swaps added to the activity feed since its last run.
+// Its cursor is kept in the database, so a restarted process resumes where it stopped.
+type SwapFeed struct {
+	DB       *gorm.DB
+	Endpoint string
+	// Name keys the cursor. Feeds reading different endpoints need different names.
+	Name     string
+	PageSize int
+	MaxPages int
+	// Window bounds the first run of a feed without a cursor to the swaps made
+	// within Window before now, so that it does not read back the whole history.
+	// Once a cursor is saved, every swap after it is read. Zero reads the whole feed.
+	Window time.Duration
+	// Registry, if set, drops swaps without an enabled token.
+	Registry *Registry
+	// Client calls the API. Defaults to DefaultClient.
+	Client *Client
+
+	now func() time.Time
+}
+
+// NewSwapFeed returns a feed of the swaps of the activity endpoint, with its own client.
+func NewSwapFeed(db *gorm.DB, endpoint string) *SwapFeed {
+	return &SwapFeed{
+		DB:       db,
+		Endpoint: endpoint,
+		Name:     QueryTypeSwap,
+		PageSize: DefaultActivityPageSize,
+		MaxPages: DefaultActivityMaxPages,
+		Window:   Window10m,
+		Client:   NewClient(ClientConfig{}),
+		now:      time.Now,
+	}
+}
+
+// Next pages through the feed until it reaches the cursor, or the start of the window
+// if there is no cursor yet, and passes the new swaps, oldest first and deduplicated by tx hash, to handle.
+// handle runs in a transaction with the update of the cursor, which only advances if
+// handle succeeds. What handle writes through tx is committed with the cursor, so
+// swaps that failed are read again by the next run without being counted twice. If MaxPages are read before the cursor is reached, nothing is handled and
+// the cursor is kept, since the swaps of the unread pages would be skipped.
+// It returns the number of new swaps.
+func (f *SwapFeed) Next(ctx context.Context, handle func(ctx context.Context, tx *gorm.DB, swaps []ParsedSwap) error) (int, error) {
+	if f.DB == nil {
+		return 0, fmt.Errorf("db is nil")
+	}
+
+	cursor, err := loadSwapCursor(ctx, f.DB, f.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	client := f.client()
+
+	var from time.Time
+	if f.Window > 0 && cursor.LastTime.IsZero() {
+		now := time.Now
+		if f.now != nil {
+			now = f.now
+		}
+		from = now().Add(-f.Window)
+	}
+
+	var (
+		fresh   []ParsedSwap // every new swap, including those dropped by the registry
+		swaps   []ParsedSwap
+		hashes  = make(map[string]bool)
+		reached bool
+		expired int
+	)
+	for page := 1; page <= f.MaxPages && !reached; page++ {
+		raw, err := fetchActivitySwapPage(ctx, client, f.Endpoint, QueryTypeSwap, page, f.PageSize)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch activity page %d: %v", page, err)
+		}
+		if len(raw) < f.PageSize {
+			reached = true
+		}
+
+		for _, swap := range parseSwapsLogged(raw) {
+			// the page may still hold unseen swaps if the feed is not strictly ordered
+			if cursor.seen(swap) {
+				reached = true
+				continue
+			}
+			if !from.IsZero() && !swap.Time.After(from) {
+				reached = true
+				expired++
+				continue
+			}
+			if swap.TxHash != "" {
+				// swaps shift to later pages while paging through a busy feed
+				if hashes[swap.TxHash] {
+					continue
+				}
+				hashes[swap.TxHash] = true
+			}
+			fresh = append(fresh, swap)
+			if f.Registry == nil || f.Registry.enabledSwapToken(swap.TokenA) || f.Registry.enabledSwapToken(swap.TokenB) {
+				swaps = append(swaps, swap)
+			}
+		}
+	}
+	if !reached {
+		return 0, fmt.Errorf("activity feed %s: %d pages of new swaps do not reach the cursor, the cursor is kept", f.Name, f.MaxPages)
+	}
+	if expired > 0 {
+		log.Printf("activity feed %s: no cursor yet, skipped %d swaps made before %s\n", f.Name, expired, from.Format(time.RFC3339))
+	}
+
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+
+	sort.SliceStable(swaps, func(i, j int) bool {
+		return swaps[i].Time.Before(swaps[j].Time)
+	})
+	err = f.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(swaps) > 0 {
+			if err := handle(ctx, tx, swaps); err != nil {
+				return err
+			}
+		}
+		cursor.advance(fresh)
+		return saveSwapCursor(ctx, tx, cursor)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(swaps), nil
+}
+
+// Range pages through the feed back to from and returns the swaps made within
+// (from, to], oldest first and deduplicated by tx hash. It ignores the cursor.
+func (f *SwapFeed) Range(ctx context.Context, from, to time.Time) ([]ParsedSwap, error) {
+	client := f.client()
+
+	var (
+		swaps   []ParsedSwap
+		hashes  = make(map[string]bool)
+		reached bool
+	)
+	for page := 1; page <= f.MaxPages && !reached; page++ {
+		raw, err := fetchActivitySwapPage(ctx, client, f.Endpoint, QueryTypeSwap, page, f.PageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch activity page %d: %v", page, err)
+		}
+		if len(raw) < f.PageSize {
+			reached = true
+		}
+
+		for _, swap := range parseSwapsLogged(raw) {
+			if !swap.Time.After(from) {
+				reached = true
+				continue
+			}
+			if swap.Time.After(to) {
+				continue
+			}
+			if swap.TxHash != "" {
+				if hashes[swap.TxHash] {
+					continue
+				}
+				hashes[swap.TxHash] = true
+			}
+			if f.Registry == nil || f.Registry.enabledSwapToken(swap.TokenA) || f.Registry.enabledSwapToken(swap.TokenB) {
+				swaps = append(swaps, swap)
+			}
+		}
+	}
+	if !reached {
+		log.Printf("activity feed %s: stopped after %d pages before reaching %s, older swaps are skipped\n", f.Name, f.MaxPages, from.Format(time.RFC3339))
+	}
+
+	sort.SliceStable(swaps, func(i, j int) bool {
+		return swaps[i].Time.Before(swaps[j].Time)
+	})
+	return swaps, nil
+}
+
+func (f *SwapFeed) client() *Client {
+	if f.Client == nil {
+		return DefaultClient
+	}
+	return f.Client
+}
@@ -0,0 +1,257 @@
+package vwap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// activityServer serves swaps newest first, paginated like the activity API.
+type activityServer struct {
+	mu    sync.Mutex
+	swaps []Swap
+	pages int
+}
+
+func (s *activityServer) prepend(swaps ...Swap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.swaps = append(swaps, s.swaps...)
+}
+
+func (s *activityServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages++
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	from := min((page-1)*limit, len(s.swaps))
+	to := min(from+limit, len(s.swaps))
+
+	json.NewEncoder(w).Encode(ActivitySwapResponse{Data: s.swaps[from:to]})
+}
+
+func testSwap(hash string, ts time.Time) Swap {
+	return Swap{
+		TxHash:       hash,
+		Time:         ts.Format(time.RFC3339),
+		TokenA:       SwapToken{Symbol: "GNS"},
+		TokenAAmount: "10",
+		TokenB:       SwapToken{Symbol: "USDC"},
+		TokenBAmount: "-20",
+		TotalUsd:     "20",
+	}
+}
+
+func TestSwapFeed(t *testing.T) {
+	db := newTestDB(t)
+	start := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	server := &activityServer{}
+	for i := 0; i < 5; i++ {
+		server.prepend(testSwap(fmt.Sprintf("0x%d", i), start.Add(time.Duration(i)*time.Minute)))
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	feed := NewSwapFeed(db, httpServer.URL+"/v1/activity?type=%s")
+	feed.PageSize = 2
+	feed.now = func() time.Time { return start.Add(5 * time.Minute) }
+
+	var got []ParsedSwap
+	collect := func(_ context.Context, _ *gorm.DB, swaps []ParsedSwap) error {
+		got = swaps
+		return nil
+	}
+
+	// the first run pages through the whole feed, oldest swaps first
+	n, err := feed.Next(context.Background(), collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "0x0", got[0].TxHash)
+	assert.Equal(t, "0x4", got[4].TxHash)
+
+	// nothing new
+	got = nil
+	n, err = feed.Next(context.Background(), collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Nil(t, got)
+
+	// new swaps, one sharing the timestamp of the last processed swap
+	server.prepend(
+		testSwap("0x6", start.Add(5*time.Minute)),
+		testSwap("0x5", start.Add(4*time.Minute)),
+	)
+	server.pages = 0
+	n, err = feed.Next(context.Background(), collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "0x5", got[0].TxHash)
+	assert.Equal(t, "0x6", got[1].TxHash)
+	// paging stops once the cursor is reached
+	assert.Equal(t, 2, server.pages)
+
+	// the cursor is not advanced if handling fails
+	server.prepend(testSwap("0x7", start.Add(6*time.Minute)))
+	_, err = feed.Next(context.Background(), func(context.Context, *gorm.DB, []ParsedSwap) error { return errors.New("boom") })
+	assert.Error(t, err)
+
+	// a new feed resumes from the stored cursor
+	feed = NewSwapFeed(db, feed.Endpoint)
+	feed.now = func() time.Time { return start.Add(6 * time.Minute) }
+	n, err = feed.Next(context.Background(), collect)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "0x7", got[0].TxHash)
+}
+
+func TestSwapFeedDeduplicatesByTxHash(t *testing.T) {
+	db := newTestDB(t)
+
+	ts := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	server := &activityServer{}
+	// a swap shifted to the next page while paging shows up twice
+	server.prepend(testSwap("0x2", ts.Add(time.Minute)), testSwap("0x1", ts), testSwap("0x1", ts))
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	feed := NewSwapFeed(db, httpServer.URL+"/v1/activity?type=%s")
+	feed.PageSize = 2
+	feed.now = func() time.Time { return ts.Add(time.Minute) }
+
+	n, err := feed.Next(context.Background(), func(context.Context, *gorm.DB, []ParsedSwap) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	cursor, err := loadSwapCursor(context.Background(), db, QueryTypeSwap)
+	assert.NoError(t, err)
+	assert.True(t, ts.Add(time.Minute).Equal(cursor.LastTime))
+	assert.Equal(t, "0x2", cursor.LastTxHashes)
+}
+
+func TestSwapFeedReadsWithinWindow(t *testing.T) {
+	db := newTestDB(t)
+	start := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	server := &activityServer{}
+	// a day of history, one swap a minute
+	for i := 0; i < 24*60; i++ {
+		server.prepend(testSwap(fmt.Sprintf("0x%d", i), start.Add(time.Duration(i)*time.Minute)))
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	now := start.Add(24 * time.Hour)
+	feed := NewSwapFeed(db, httpServer.URL+"/v1/activity?type=%s")
+	feed.PageSize = 4
+	feed.now = func() time.Time { return now }
+
+	// without a cursor, only the swaps of the window are read
+	var got []ParsedSwap
+	n, err := feed.Next(context.Background(), func(_ context.Context, _ *gorm.DB, swaps []ParsedSwap) error {
+		got = swaps
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 9, n)
+	assert.True(t, got[0].Time.After(now.Add(-Window10m)))
+	assert.Equal(t, 3, server.pages)
+
+	// after downtime, every swap since the cursor is read, even beyond the window
+	for i := 0; i < 3; i++ {
+		server.prepend(testSwap(fmt.Sprintf("0xlate%d", i), now.Add(time.Duration(i+1)*time.Second)))
+	}
+	now = now.Add(time.Hour)
+	n, err = feed.Next(context.Background(), func(_ context.Context, _ *gorm.DB, swaps []ParsedSwap) error {
+		got = swaps
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "0xlate0", got[0].TxHash)
+
+	// a backlog beyond MaxPages is not skipped: the cursor is kept
+	for i := 0; i < 8; i++ {
+		server.prepend(testSwap(fmt.Sprintf("0xnew%d", i), now.Add(time.Duration(i+1)*time.Second)))
+	}
+	cursor, err := loadSwapCursor(context.Background(), db, QueryTypeSwap)
+	assert.NoError(t, err)
+
+	feed.MaxPages = 2
+	_, err = feed.Next(context.Background(), func(context.Context, *gorm.DB, []ParsedSwap) error {
+		t.Fatal("swaps handled before the cursor was reached")
+		return nil
+	})
+	assert.Error(t, err)
+
+	kept, err := loadSwapCursor(context.Background(), db, QueryTypeSwap)
+	assert.NoError(t, err)
+	assert.True(t, cursor.LastTime.Equal(kept.LastTime))
+
+	feed.MaxPages = DefaultActivityMaxPages
+	n, err = feed.Next(context.Background(), func(context.Context, *gorm.DB, []ParsedSwap) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 8, n)
+}
+
+func TestSwapFeedRetriesWithoutCountingTwice(t *testing.T) {
+	db := newTestDB(t)
+	start := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	server := &activityServer{}
+	server.prepend(testSwap("0x1", start.Add(time.Minute)))
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	feed := NewSwapFeed(db, httpServer.URL+"/v1/activity?type=%s")
+	feed.now = func() time.Time { return start.Add(2 * time.Minute) }
+	calculator := NewCalculator(db, nil, Config{})
+
+	fail := true
+	handle := func(ctx context.Context, tx *gorm.DB, swaps []ParsedSwap) error {
+		if _, err := calculator.PairVWAPTx(ctx, tx, swaps); err != nil {
+			return err
+		}
+		candles, err := NewCandleBuilder(Resolution1m)
+		if err != nil {
+			return err
+		}
+		candles.Add(swaps...)
+		if err := StoreCandles(ctx, tx, candles.Candles()); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	// the pair VWAP and the candles were written before the handler failed
+	_, err := feed.Next(context.Background(), handle)
+	assert.Error(t, err)
+
+	fail = false
+	n, err := feed.Next(context.Background(), handle)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var row VWAPData
+	assert.NoError(t, db.Where("pair <> ''").First(&row).Error)
+	assert.Equal(t, "10", row.TotalVolume.String())
+
+	var candles []Candle
+	assert.NoError(t, db.Where("pair = ''").Find(&candles).Error)
+	for _, candle := range candles {
+		assert.Equal(t, 1, candle.Trades, candle.TokenName)
+	}
+	assert.NotEmpty(t, candles)
+}
@@ -0,0 +1,175 @@
+package vwap
+
+import (
+	"sort"
+	"time"
+)
+
+// Names of the built-in aggregators, as stored in VWAPData.Aggregator.
+const (
+	AggregatorVWAP   = "vwap"
+	AggregatorTWAP   = "twap"
+	AggregatorEMA    = "ema"
+	AggregatorMedian = "median"
+)
+
+// Aggregator reduces the trades of a token or pair to a single price.
+// Trades without volume carry no price information and are ignored.
+type Aggregator interface {
+	// Name identifies the aggregator in stored rows.
+	Name() string
+	// Aggregate returns the price of the trades. ok is false if no trade has volume.
+	Aggregate(trades []TradeData) (price Decimal, ok bool)
+}
+
+// VWAPAggregator computes the volume weighted average price.
+type VWAPAggregator struct{}
+
+func (VWAPAggregator) Name() string { return AggregatorVWAP }
+
+func (VWAPAggregator) Aggregate(trades []TradeData) (Decimal, bool) {
+	vwap, volume := weightedAverage(trades)
+	return vwap, !volume.IsZero()
+}
+
+// TWAPAggregator computes the time weighted average price. Each price holds
+// from its trade until the next one; the last price holds until Now.
+// Being independent of trade size, it resists single large swaps.
+type TWAPAggregator struct {
+	// Now returns the end of the averaging period. It defaults to time.Now.
+	Now func() time.Time
+}
+
+func (TWAPAggregator) Name() string { return AggregatorTWAP }
+
+func (a TWAPAggregator) Aggregate(trades []TradeData) (Decimal, bool) {
+	sorted := tradesWithVolume(trades)
+	if len(sorted) == 0 {
+		return Decimal{}, false
+	}
+
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	end := now().Unix()
+
+	var numerator, denominator Decimal
+	for i, trade := range sorted {
+		until := end
+		if i+1 < len(sorted) {
+			until = int64(sorted[i+1].Timestamp)
+		}
+
+		duration := until - int64(trade.Timestamp)
+		if duration <= 0 {
+			continue
+		}
+
+		weight := NewDecimalFromInt(duration)
+		numerator = numerator.Add(trade.Ratio.Mul(weight))
+		denominator = denominator.Add(weight)
+	}
+
+	// all trades happened at the same instant
+	if denominator.IsZero() {
+		return sorted[len(sorted)-1].Ratio, true
+	}
+
+	return numerator.Quo(denominator), true
+}
+
+// DefaultEMAPeriod is the EMA period used when EMAAggregator.Period is not set.
+const DefaultEMAPeriod = 10
+
+// EMAAggregator computes the exponential moving average of the trade prices,
+// in trade order, with a smoothing factor of 2 / (Period + 1).
+type EMAAggregator struct {
+	Period int
+}
+
+func (EMAAggregator) Name() string { return AggregatorEMA }
+
+func (a EMAAggregator) Aggregate(trades []TradeData) (Decimal, bool) {
+	sorted := tradesWithVolume(trades)
+	if len(sorted) == 0 {
+		return Decimal{}, false
+	}
+
+	period := a.Period
+	if period <= 0 {
+		period = DefaultEMAPeriod
+	}
+	alpha := NewDecimalFromInt(2).Quo(NewDecimalFromInt(int64(period) + 1))
+	keep := NewDecimalFromInt(1).Sub(alpha)
+
+	ema := sorted[0].Ratio
+	for _, trade := range sorted[1:] {
+		ema = trade.Ratio.Mul(alpha).Add(ema.Mul(keep))
+	}
+
+	return ema, true
+}
+
+// MedianAggregator computes the median trade price. With an even number of
+// trades, it returns the mean of the two middle prices.
+type MedianAggregator struct{}
+
+func (MedianAggregator) Name() string { return AggregatorMedian }
+
+func (MedianAggregator) Aggregate(trades []TradeData) (Decimal, bool) {
+	filtered := tradesWithVolume(trades)
+	if len(filtered) == 0 {
+		return Decimal{}, false
+	}
+
+	return median(filtered), true
+}
+
+// median returns the median price of trades, which must not be empty.
+func median(trades []TradeData) Decimal {
+	prices := make([]Decimal, 0, len(trades))
+	for _, trade := range trades {
+		prices = append(prices, trade.Ratio)
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Cmp(prices[j]) < 0
+	})
+
+	middle := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return prices[middle]
+	}
+	return prices[middle-1].Add(prices[middle]).Quo(NewDecimalFromInt(2))
+}
+
+// tradesWithVolume returns the trades with a non-zero volume, ordered by timestamp.
+func tradesWithVolume(trades []TradeData) []TradeData {
+	filtered := make([]TradeData, 0, len(trades))
+	for _, trade := range trades {
+		if !trade.Volume.IsZero() {
+			filtered = append(filtered, trade)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Timestamp < filtered[j].Timestamp
+	})
+	return filtered
+}
+
+// AggregatorByName returns the built-in aggregator with the given name.
+func AggregatorByName(name string) (Aggregator, bool) {
+	switch name {
+	case AggregatorVWAP:
+		return VWAPAggregator{}, true
+	case AggregatorTWAP:
+		return TWAPAggregator{}, true
+	case AggregatorEMA:
+		return EMAAggregator{}, true
+	case AggregatorMedian:
+		return MedianAggregator{}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,80 @@
+package vwap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func aggregatorTrades() []TradeData {
+	return []TradeData{
+		{TokenName: "Token1", Volume: MustParseDecimal("100"), Ratio: MustParseDecimal("1"), Timestamp: 1000},
+		{TokenName: "Token1", Volume: MustParseDecimal("0"), Ratio: MustParseDecimal("50"), Timestamp: 1010},
+		{TokenName: "Token1", Volume: MustParseDecimal("900"), Ratio: MustParseDecimal("3"), Timestamp: 1060},
+		{TokenName: "Token1", Volume: MustParseDecimal("100"), Ratio: MustParseDecimal("2"), Timestamp: 1030},
+	}
+}
+
+func TestVWAPAggregator(t *testing.T) {
+	// (100*1 + 100*2 + 900*3) / 1100
+	price, ok := VWAPAggregator{}.Aggregate(aggregatorTrades())
+	assert.True(t, ok)
+	assert.True(t, NewDecimalFromInt(3000).Quo(NewDecimalFromInt(1100)).Equal(price))
+
+	_, ok = VWAPAggregator{}.Aggregate([]TradeData{{Volume: MustParseDecimal("0"), Ratio: MustParseDecimal("1")}})
+	assert.False(t, ok)
+}
+
+func TestTWAPAggregator(t *testing.T) {
+	aggregator := TWAPAggregator{Now: func() time.Time { return time.Unix(1100, 0) }}
+
+	// 1 for 30s, 2 for 30s, 3 for 40s; the zero-volume trade is ignored
+	price, ok := aggregator.Aggregate(aggregatorTrades())
+	assert.True(t, ok)
+	assert.Equal(t, "2.1", price.String())
+
+	// a single trade at the end of the period
+	price, ok = aggregator.Aggregate([]TradeData{{Volume: MustParseDecimal("1"), Ratio: MustParseDecimal("4"), Timestamp: 1100}})
+	assert.True(t, ok)
+	assert.Equal(t, "4", price.String())
+
+	_, ok = aggregator.Aggregate(nil)
+	assert.False(t, ok)
+}
+
+func TestEMAAggregator(t *testing.T) {
+	// alpha = 2 / (3 + 1) = 0.5: 1 -> 1.5 -> 2.25
+	price, ok := EMAAggregator{Period: 3}.Aggregate(aggregatorTrades())
+	assert.True(t, ok)
+	assert.Equal(t, "2.25", price.String())
+
+	// default period: alpha = 2 / 11
+	price, ok = EMAAggregator{}.Aggregate(aggregatorTrades()[:2])
+	assert.True(t, ok)
+	assert.Equal(t, "1", price.String())
+}
+
+func TestMedianAggregator(t *testing.T) {
+	price, ok := MedianAggregator{}.Aggregate(aggregatorTrades())
+	assert.True(t, ok)
+	assert.Equal(t, "2", price.String())
+
+	price, ok = MedianAggregator{}.Aggregate(aggregatorTrades()[:3])
+	assert.True(t, ok)
+	assert.Equal(t, "2", price.String())
+
+	_, ok = MedianAggregator{}.Aggregate(aggregatorTrades()[1:2])
+	assert.False(t, ok)
+}
+
+func TestAggregatorByName(t *testing.T) {
+	for _, name := range []string{AggregatorVWAP, AggregatorTWAP, AggregatorEMA, AggregatorMedian} {
+		aggregator, ok := AggregatorByName(name)
+		assert.True(t, ok)
+		assert.Equal(t, name, aggregator.Name())
+	}
+
+	_, ok := AggregatorByName("mode")
+	assert.False(t, ok)
+}
@@ -0,0 +1,206 @@
+package vwap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const historySuffix = "/history"
+
+// defaultHistoryRange is used when a history request has no "from" parameter.
+const defaultHistoryRange = 24 * time.Hour
+
+// VWAPPrice is a stored VWAP as served by the API.
+// Its fields follow the naming of TokenPrice so that price API consumers can switch over.
+type VWAPPrice struct {
+	Path         string     `json:"path"`
+	USD          string     `json:"usd"`
+	VolumeUSD    string     `json:"volumeUsd"`
+	CalculatedAt time.Time  `json:"calculatedAt"`
+	Status       VWAPStatus `json:"status"`
+}
+
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// VWAPResponse wraps a single VWAPPrice, like PricesResponse.
+type VWAPResponse struct {
+	Error *APIError  `json:"error"`
+	Data  *VWAPPrice `json:"data"`
+}
+
+// VWAPListResponse wraps a list of VWAPPrice, like PricesResponse.
+type VWAPListResponse struct {
+	Error *APIError   `json:"error"`
+	Data  []VWAPPrice `json:"data"`
+}
+
+func newVWAPPrice(data VWAPData) VWAPPrice {
+	return VWAPPrice{
+		Path:         data.TokenName,
+		USD:          data.VWAP.String(),
+		VolumeUSD:    data.TotalVolume.String(),
+		CalculatedAt: data.CalculatedAt,
+		Status:       data.Status,
+	}
+}
+
+// NewServer returns an HTTP handler serving the VWAP data stored in db:
+//
+//	GET /vwap                                          latest VWAP of every token
+//	GET /vwap/{token}                                  latest VWAP of a token
+//	GET /vwap/{token}/history?from=&to=&interval=      VWAP history of a token
+//
+// Token paths contain slashes and are used as is, e.g. /vwap/gno.land/r/demo/foo.
+// from and to accept RFC 3339 times or unix seconds; interval is a Go duration
+// such as "1h" and keeps the latest row of every interval bucket.
+// Every endpoint accepts an aggregator parameter ("vwap" by default) to serve
+// TWAP, EMA or median prices instead.
+func NewServer(db *gorm.DB) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /vwap", func(w http.ResponseWriter, r *http.Request) {
+		handleLatestAll(db, w, r, aggregatorParam(r))
+	})
+	mux.HandleFunc("GET /vwap/{token...}", func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+		if strings.HasSuffix(token, historySuffix) {
+			key := seriesKey{tokenName: strings.TrimSuffix(token, historySuffix), aggregator: aggregatorParam(r)}
+			handleHistory(db, w, r, key)
+			return
+		}
+		handleLatest(db, w, r, seriesKey{tokenName: token, aggregator: aggregatorParam(r)})
+	})
+	return mux
+}
+
+func aggregatorParam(r *http.Request) string {
+	if aggregator := r.URL.Query().Get("aggregator"); aggregator != "" {
+		return aggregator
+	}
+	return AggregatorVWAP
+}
+
+func handleLatestAll(db *gorm.DB, w http.ResponseWriter, r *http.Request, aggregator string) {
+	rows, err := latestVWAPs(r.Context(), db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	prices := make([]VWAPPrice, 0, len(rows))
+	for _, row := range rows {
+		if row.Pair != "" || row.Aggregator != aggregator {
+			continue
+		}
+		prices = append(prices, newVWAPPrice(row))
+	}
+	writeJSON(w, http.StatusOK, VWAPListResponse{Data: prices})
+}
+
+func handleLatest(db *gorm.DB, w http.ResponseWriter, r *http.Request, key seriesKey) {
+	row, err := latestSeries(r.Context(), db, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no %s found for token %s", key.aggregator, key.tokenName))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	price := newVWAPPrice(row)
+	writeJSON(w, http.StatusOK, VWAPResponse{Data: &price})
+}
+
+func handleHistory(db *gorm.DB, w http.ResponseWriter, r *http.Request, key seriesKey) {
+	query := r.URL.Query()
+
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		parsed, err := parseQueryTime(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid to: %v", err))
+			return
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultHistoryRange)
+	if value := query.Get("from"); value != "" {
+		parsed, err := parseQueryTime(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from: %v", err))
+			return
+		}
+		from = parsed
+	}
+
+	var interval time.Duration
+	if value := query.Get("interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid interval: %q", value))
+			return
+		}
+		interval = parsed
+	}
+
+	rows, err := vwapHistory(r.Context(), db, key, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	prices := make([]VWAPPrice, 0, len(rows))
+	for _, row := range downsample(rows, interval) {
+		prices = append(prices, newVWAPPrice(row))
+	}
+	writeJSON(w, http.StatusOK, VWAPListResponse{Data: prices})
+}
+
+// downsample keeps the latest row of every interval bucket. rows must be sorted by time.
+func downsample(rows []VWAPData, interval time.Duration) []VWAPData {
+	if interval <= 0 {
+		return rows
+	}
+
+	sampled := make([]VWAPData, 0, len(rows))
+	for _, row := range rows {
+		bucket := row.CalculatedAt.Truncate(interval)
+		if n := len(sampled); n > 0 && sampled[n-1].CalculatedAt.Truncate(interval).Equal(bucket) {
+			sampled[n-1] = row
+			continue
+		}
+		sampled = append(sampled, row)
+	}
+	return sampled
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, VWAPResponse{Error: &APIError{Code: status, Message: err.Error()}})
+}
@@ -0,0 +1,95 @@
+package vwap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	rows := []struct {
+		token string
+		vwap  string
+		at    time.Duration
+	}{
+		{"gno.land/r/demo/foo", "1.1", 0},
+		{"gno.land/r/demo/foo", "1.2", 10 * time.Minute},
+		{"gno.land/r/demo/foo", "1.3", 70 * time.Minute},
+		{"gno.land/r/demo/bar", "30.5", 10 * time.Minute},
+	}
+	for _, row := range rows {
+		assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: row.token, aggregator: AggregatorVWAP}, Window10m, MustParseDecimal(row.vwap), MustParseDecimal("100"), base.Add(row.at), StatusComputed)}))
+	}
+
+	server := httptest.NewServer(NewServer(db))
+	defer server.Close()
+
+	get := func(path string, v any) int {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		return resp.StatusCode
+	}
+
+	t.Run("latest", func(t *testing.T) {
+		var resp VWAPResponse
+		assert.Equal(t, http.StatusOK, get("/vwap/gno.land/r/demo/foo", &resp))
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, "gno.land/r/demo/foo", resp.Data.Path)
+		assert.Equal(t, "1.3", resp.Data.USD)
+		assert.Equal(t, "100", resp.Data.VolumeUSD)
+		assert.True(t, base.Add(70*time.Minute).Equal(resp.Data.CalculatedAt))
+	})
+
+	t.Run("latest unknown token", func(t *testing.T) {
+		var resp VWAPResponse
+		assert.Equal(t, http.StatusNotFound, get("/vwap/gno.land/r/demo/qux", &resp))
+		assert.Equal(t, http.StatusNotFound, resp.Error.Code)
+		assert.Nil(t, resp.Data)
+	})
+
+	t.Run("latest all", func(t *testing.T) {
+		var resp VWAPListResponse
+		assert.Equal(t, http.StatusOK, get("/vwap", &resp))
+		assert.Len(t, resp.Data, 2)
+		assert.Equal(t, "gno.land/r/demo/bar", resp.Data[0].Path)
+		assert.Equal(t, "30.5", resp.Data[0].USD)
+		assert.Equal(t, "gno.land/r/demo/foo", resp.Data[1].Path)
+		assert.Equal(t, "1.3", resp.Data[1].USD)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		from := strconv.FormatInt(base.Unix(), 10)
+		to := base.Add(2 * time.Hour).Format(time.RFC3339)
+
+		var resp VWAPListResponse
+		assert.Equal(t, http.StatusOK, get("/vwap/gno.land/r/demo/foo/history?from="+from+"&to="+to, &resp))
+		assert.Len(t, resp.Data, 3)
+		assert.Equal(t, "1.1", resp.Data[0].USD)
+		assert.Equal(t, "1.3", resp.Data[2].USD)
+
+		assert.Equal(t, http.StatusOK, get("/vwap/gno.land/r/demo/foo/history?from="+from+"&to="+to+"&interval=1h", &resp))
+		assert.Len(t, resp.Data, 2)
+		assert.Equal(t, "1.2", resp.Data[0].USD)
+		assert.Equal(t, "1.3", resp.Data[1].USD)
+	})
+
+	t.Run("history bad request", func(t *testing.T) {
+		var resp VWAPListResponse
+		assert.Equal(t, http.StatusBadRequest, get("/vwap/gno.land/r/demo/foo/history?interval=soon", &resp))
+		assert.Equal(t, http.StatusBadRequest, resp.Error.Code)
+	})
+}
@@ -0,0 +1,162 @@
+package vwap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// bucketEnd returns the end of the bucket holding t, buckets covering (end-interval, end].
+func bucketEnd(t time.Time, interval time.Duration) time.Time {
+	end := t.Truncate(interval)
+	if end.Equal(t) {
+		return end
+	}
+	return end.Add(interval)
+}
+
+// aggregatorAt returns the aggregator evaluated as of t instead of now.
+func aggregatorAt(aggregator Aggregator, t time.Time) Aggregator {
+	if twap, ok := aggregator.(TWAPAggregator); ok {
+		twap.Now = func() time.Time { return t }
+		return twap
+	}
+	return aggregator
+}
+
+// Backfill calculates the prices of the tokens and pairs traded in the swaps for every
+// bucket of the interval between from and to, both rounded down to the interval.
+// Each bucket covers (end-interval, end] and its rows are stored as calculated at its end.
+// A bucket without trades carries the price of the previous one forward.
+//
+// Rows that already exist are left untouched, so a range can be backfilled again.
+// The last prices used by VWAP are not affected. It returns the number of rows stored.
+func (c *Calculator) Backfill(ctx context.Context, swaps []ParsedSwap, from, to time.Time, interval time.Duration) (int, error) {
+	if c.db == nil {
+		return 0, fmt.Errorf("db is nil")
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("invalid interval: %s", interval)
+	}
+	start, end := from.UTC().Truncate(interval), to.UTC().Truncate(interval)
+	if !start.Before(end) {
+		return 0, fmt.Errorf("empty range: %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	aggregators := c.config.Aggregators
+	if len(aggregators) == 0 {
+		aggregators = []Aggregator{VWAPAggregator{}}
+	}
+	registry := c.config.Registry
+
+	// trades of each token and pair, by bucket end
+	trades := make(map[seriesKey]map[time.Time][]TradeData)
+	add := func(key seriesKey, at time.Time, trade TradeData) {
+		if trades[key] == nil {
+			trades[key] = make(map[time.Time][]TradeData)
+		}
+		trades[key][at] = append(trades[key][at], trade)
+	}
+
+	for _, swap := range swaps {
+		if !swap.Time.After(start) || swap.Time.After(end) {
+			continue
+		}
+		enabledA := registry == nil || registry.enabledSwapToken(swap.TokenA)
+		enabledB := registry == nil || registry.enabledSwapToken(swap.TokenB)
+		if !enabledA && !enabledB {
+			continue
+		}
+
+		at := bucketEnd(swap.Time.UTC(), interval)
+		for _, trade := range swapToTrades(swap) {
+			if trade.TokenName == swap.TokenA.ID() && !enabledA || trade.TokenName == swap.TokenB.ID() && !enabledB {
+				continue
+			}
+			add(seriesKey{tokenName: trade.TokenName}, at, trade)
+		}
+		trade := swapToPairTrade(swap)
+		add(seriesKey{tokenName: trade.TokenName, pair: trade.Pair.String()}, at, trade)
+	}
+
+	keys := make([]seriesKey, 0, len(trades))
+	for key := range trades {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tokenName != keys[j].tokenName {
+			return keys[i].tokenName < keys[j].tokenName
+		}
+		return keys[i].pair < keys[j].pair
+	})
+
+	stored := 0
+	for _, key := range keys {
+		seriesAggregators := aggregators
+		if key.pair != "" {
+			// like PairVWAP, pairs are only priced with VWAP
+			seriesAggregators = []Aggregator{VWAPAggregator{}}
+		}
+
+		for _, aggregator := range seriesAggregators {
+			aggregated := key
+			aggregated.aggregator = aggregator.Name()
+
+			n, err := c.backfillSeries(ctx, aggregated, trades[key], aggregator, start, end, interval)
+			stored += n
+			if err != nil {
+				return stored, err
+			}
+		}
+	}
+
+	return stored, nil
+}
+
+// backfillSeries stores a row of the series for every bucket of (start, end].
+func (c *Calculator) backfillSeries(ctx context.Context, key seriesKey, trades map[time.Time][]TradeData, aggregator Aggregator, start, end time.Time, interval time.Duration) (int, error) {
+	row, ok, err := latestSeriesAt(ctx, c.db, key, start)
+	if err != nil {
+		return 0, err
+	}
+	ok = ok && row.Status != StatusNoData
+	lastPrice := row.VWAP
+
+	stored := 0
+	for at := start.Add(interval); !at.After(end); at = at.Add(interval) {
+		if err := ctx.Err(); err != nil {
+			return stored, err
+		}
+
+		// like VWAP and PairVWAP, the trades of tokens and pairs are filtered
+		bucket := c.config.Filter.Filter(trades[at])
+
+		var totalVolume Decimal
+		for _, trade := range bucket {
+			totalVolume = totalVolume.Add(trade.Volume)
+		}
+
+		status := StatusComputed
+		price, computed := aggregatorAt(aggregator, at).Aggregate(bucket)
+		switch {
+		case computed:
+			lastPrice, ok = price, true
+		case ok:
+			price, status = lastPrice, StatusCarriedForward
+		default:
+			// nothing known yet
+			continue
+		}
+
+		wrote, err := storeSeriesOnce(ctx, c.db, key, interval, price, totalVolume, at, status)
+		if err != nil {
+			return stored, fmt.Errorf("%w: %v", ErrStorage, err)
+		}
+		if wrote {
+			stored++
+		}
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,149 @@
+package vwap
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfill(t *testing.T) {
+	db := newTestDB(t)
+	calculator := NewCalculator(db, nil, Config{})
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	swaps := []ParsedSwap{
+		candleSwap(t, base, "10", "10"), // before the first bucket
+		candleSwap(t, base.Add(1*time.Minute), "10", "20"),
+		candleSwap(t, base.Add(30*time.Minute), "10", "30"), // end of the last bucket
+		candleSwap(t, base.Add(30*time.Minute+time.Second), "10", "40"),
+	}
+
+	// the bounds are rounded down to the interval
+	n, err := calculator.Backfill(context.Background(), swaps, base.Add(time.Minute), base.Add(35*time.Minute), Window10m)
+	assert.NoError(t, err)
+	// GNS, USDC and GNS/USDC over 3 buckets
+	assert.Equal(t, 9, n)
+
+	var rows []VWAPData
+	db.Where("token_name = ? AND pair = ?", "GNS", "").Order("calculated_at").Find(&rows)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, base.Add(10*time.Minute), rows[0].CalculatedAt.UTC())
+		assert.Equal(t, StatusComputed, rows[0].Status)
+		assert.Equal(t, "2", rows[0].VWAP.String())
+		assert.Equal(t, "20", rows[0].TotalVolume.String())
+
+		assert.Equal(t, base.Add(20*time.Minute), rows[1].CalculatedAt.UTC())
+		assert.Equal(t, StatusCarriedForward, rows[1].Status)
+		assert.Equal(t, "2", rows[1].VWAP.String())
+		assert.True(t, rows[1].TotalVolume.IsZero())
+
+		assert.Equal(t, base.Add(30*time.Minute), rows[2].CalculatedAt.UTC())
+		assert.Equal(t, StatusComputed, rows[2].Status)
+		assert.Equal(t, "3", rows[2].VWAP.String())
+	}
+
+	// the live last prices are untouched
+	calculator.mu.Lock()
+	assert.Empty(t, calculator.lastPrices)
+	calculator.mu.Unlock()
+
+	// backfilling the range again stores nothing
+	n, err = calculator.Backfill(context.Background(), swaps, base, base.Add(30*time.Minute), Window10m)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	var count int64
+	db.Model(&VWAPData{}).Count(&count)
+	assert.Equal(t, int64(9), count)
+}
+
+func TestBackfillCarriesStoredPriceForward(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "GNS", aggregator: AggregatorVWAP}, Window10m, MustParseDecimal("1.5"), MustParseDecimal("10"), base.Add(-time.Hour), StatusComputed)}))
+
+	swaps := []ParsedSwap{candleSwap(t, base.Add(15*time.Minute), "10", "20")}
+	n, err := NewCalculator(db, nil, Config{}).Backfill(context.Background(), swaps, base, base.Add(20*time.Minute), Window10m)
+	assert.NoError(t, err)
+	// GNS in both buckets, USDC and the pair only once traded
+	assert.Equal(t, 4, n)
+
+	var rows []VWAPData
+	db.Where("token_name = ? AND pair = ? AND calculated_at > ?", "GNS", "", base).Order("calculated_at").Find(&rows)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, StatusCarriedForward, rows[0].Status)
+		assert.Equal(t, "1.5", rows[0].VWAP.String())
+		assert.Equal(t, StatusComputed, rows[1].Status)
+		assert.Equal(t, "2", rows[1].VWAP.String())
+	}
+}
+
+func TestBackfillFiltersTokensAndPairs(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	swaps := []ParsedSwap{
+		candleSwap(t, base.Add(2*time.Minute), "10", "20"),
+		candleSwap(t, base.Add(4*time.Minute), "1", "5"), // below the minimum volume
+	}
+	filter := NewTradeFilter(FilterConfig{MinVolume: MustParseDecimal("10")})
+	_, err := NewCalculator(db, nil, Config{Filter: filter}).Backfill(context.Background(), swaps, base, base.Add(10*time.Minute), Window10m)
+	assert.NoError(t, err)
+
+	var token, pair VWAPData
+	assert.NoError(t, db.Where("token_name = ? AND pair = ?", "GNS", "").First(&token).Error)
+	assert.Equal(t, "2", token.VWAP.String())
+	assert.NoError(t, db.Where("pair <> ?", "").First(&pair).Error)
+	assert.Equal(t, "2", pair.VWAP.String())
+	assert.Equal(t, "10", pair.TotalVolume.String())
+}
+
+func TestBackfillTWAPAsOfBucketEnd(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	swaps := []ParsedSwap{
+		candleSwap(t, base.Add(2*time.Minute), "10", "20"),
+		candleSwap(t, base.Add(6*time.Minute), "10", "60"),
+	}
+	_, err := NewCalculator(db, nil, Config{Aggregators: []Aggregator{TWAPAggregator{}}}).Backfill(context.Background(), swaps, base, base.Add(10*time.Minute), Window10m)
+	assert.NoError(t, err)
+
+	// 2 for 4 minutes, then 6 for 4 minutes until the end of the bucket
+	var row VWAPData
+	db.Where("token_name = ? AND aggregator = ?", "GNS", AggregatorTWAP).First(&row)
+	assert.Equal(t, "4", row.VWAP.String())
+}
+
+func TestSwapFeedRange(t *testing.T) {
+	db := newTestDB(t)
+	start := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	server := &activityServer{}
+	for i := 0; i < 6; i++ {
+		server.prepend(testSwap(fmt.Sprintf("0x%d", i), start.Add(time.Duration(i)*time.Minute)))
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	feed := NewSwapFeed(db, httpServer.URL+"/v1/activity?type=%s")
+	feed.PageSize = 2
+
+	swaps, err := feed.Range(context.Background(), start.Add(time.Minute), start.Add(3*time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, swaps, 2) {
+		assert.Equal(t, "0x2", swaps[0].TxHash)
+		assert.Equal(t, "0x3", swaps[1].TxHash)
+	}
+	// paging stops once the start of the range is reached
+	assert.Equal(t, 3, server.pages)
+
+	// the cursor is not used
+	cursor, err := loadSwapCursor(context.Background(), db, feed.Name)
+	assert.NoError(t, err)
+	assert.True(t, cursor.LastTime.IsZero())
+}
@@ -0,0 +1,225 @@
+package vwap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Resolution is the duration of a candle, e.g. "10m".
+type Resolution string
+
+const (
+	Resolution1m  Resolution = "1m"
+	Resolution5m  Resolution = "5m"
+	Resolution10m Resolution = "10m"
+	Resolution1h  Resolution = "1h"
+	Resolution1d  Resolution = "1d"
+)
+
+// Resolutions lists every supported resolution, shortest first.
+var Resolutions = []Resolution{Resolution1m, Resolution5m, Resolution10m, Resolution1h, Resolution1d}
+
+// Duration returns the length of a candle of the resolution, or 0 if it is unknown.
+func (r Resolution) Duration() time.Duration {
+	switch r {
+	case Resolution1m:
+		return time.Minute
+	case Resolution5m:
+		return 5 * time.Minute
+	case Resolution10m:
+		return Window10m
+	case Resolution1h:
+		return Window1h
+	case Resolution1d:
+		return Window24h
+	default:
+		return 0
+	}
+}
+
+// The schema of candles is defined by the migrations, see migrate.go.
+
+// Candle is an OHLCV bar of a token, priced in USD and with USD volume, or of a pair
+// if Pair is set, priced in quote per base and with base volume.
+type Candle struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	TokenName  string     `gorm:"size:255;not null;uniqueIndex:idx_candle"`
+	Pair       string     `gorm:"size:255;not null;default:'';uniqueIndex:idx_candle"`
+	Resolution Resolution `gorm:"size:10;not null;uniqueIndex:idx_candle"`
+	OpenTime   time.Time  `gorm:"not null;uniqueIndex:idx_candle"`
+	Open       Decimal
+	High       Decimal
+	Low        Decimal
+	Close      Decimal
+	Volume     Decimal
+	VWAP       Decimal
+	Trades     int
+	// FirstTradeAt and LastTradeAt tell which trades set Open and Close,
+	// so that candles built from separate batches of swaps can be merged.
+	FirstTradeAt time.Time
+	LastTradeAt  time.Time
+}
+
+type candleKey struct {
+	tokenName  string
+	pair       string
+	resolution Resolution
+	openTime   time.Time
+}
+
+func (c Candle) key() candleKey {
+	return candleKey{tokenName: c.TokenName, pair: c.Pair, resolution: c.Resolution, openTime: c.OpenTime.UTC()}
+}
+
+// merge adds the trades of other, a candle of the same bucket, to c.
+func (c *Candle) merge(other Candle) {
+	if c.Trades == 0 {
+		id, createdAt := c.ID, c.CreatedAt
+		*c = other
+		c.ID, c.CreatedAt = id, createdAt
+		return
+	}
+
+	if other.FirstTradeAt.Before(c.FirstTradeAt) {
+		c.Open = other.Open
+		c.FirstTradeAt = other.FirstTradeAt
+	}
+	if !other.LastTradeAt.Before(c.LastTradeAt) {
+		c.Close = other.Close
+		c.LastTradeAt = other.LastTradeAt
+	}
+	if other.High.Cmp(c.High) > 0 {
+		c.High = other.High
+	}
+	if other.Low.Cmp(c.Low) < 0 {
+		c.Low = other.Low
+	}
+
+	volume := c.Volume.Add(other.Volume)
+	if !volume.IsZero() {
+		c.VWAP = c.VWAP.Mul(c.Volume).Add(other.VWAP.Mul(other.Volume)).Quo(volume)
+	}
+	c.Volume = volume
+	c.Trades += other.Trades
+}
+
+// CandleBuilder aggregates swaps into candles of every token and pair.
+type CandleBuilder struct {
+	resolutions []Resolution
+	candles     map[candleKey]*Candle
+}
+
+// NewCandleBuilder returns a builder for the given resolutions, or for every
+// supported resolution if none is given.
+func NewCandleBuilder(resolutions ...Resolution) (*CandleBuilder, error) {
+	if len(resolutions) == 0 {
+		resolutions = Resolutions
+	}
+	for _, resolution := range resolutions {
+		if resolution.Duration() == 0 {
+			return nil, fmt.Errorf("unsupported candle resolution: %q", resolution)
+		}
+	}
+
+	return &CandleBuilder{
+		resolutions: resolutions,
+		candles:     make(map[candleKey]*Candle),
+	}, nil
+}
+
+// Add adds the trades of the swaps to the candles. Swaps may be added in any order.
+func (b *CandleBuilder) Add(swaps ...ParsedSwap) {
+	for _, swap := range swaps {
+		for _, trade := range swapToTrades(swap) {
+			b.addTrade(trade, swap.Time)
+		}
+		b.addTrade(swapToPairTrade(swap), swap.Time)
+	}
+}
+
+func (b *CandleBuilder) addTrade(trade TradeData, at time.Time) {
+	// the price of a trade without volume is meaningless
+	if trade.Volume.IsZero() {
+		return
+	}
+
+	for _, resolution := range b.resolutions {
+		candle := Candle{
+			TokenName:    trade.TokenName,
+			Pair:         trade.Pair.String(),
+			Resolution:   resolution,
+			OpenTime:     at.UTC().Truncate(resolution.Duration()),
+			Open:         trade.Ratio,
+			High:         trade.Ratio,
+			Low:          trade.Ratio,
+			Close:        trade.Ratio,
+			Volume:       trade.Volume,
+			VWAP:         trade.Ratio,
+			Trades:       1,
+			FirstTradeAt: at,
+			LastTradeAt:  at,
+		}
+
+		key := candle.key()
+		if existing, ok := b.candles[key]; ok {
+			existing.merge(candle)
+			continue
+		}
+		b.candles[key] = &candle
+	}
+}
+
+// Candles returns the candles built so far, ordered by resolution, token, pair and time.
+func (b *CandleBuilder) Candles() []Candle {
+	candles := make([]Candle, 0, len(b.candles))
+	for _, candle := range b.candles {
+		candles = append(candles, *candle)
+	}
+
+	sort.Slice(candles, func(i, j int) bool {
+		a, c := candles[i], candles[j]
+		if a.Resolution != c.Resolution {
+			return a.Resolution.Duration() < c.Resolution.Duration()
+		}
+		if a.TokenName != c.TokenName {
+			return a.TokenName < c.TokenName
+		}
+		if a.Pair != c.Pair {
+			return a.Pair < c.Pair
+		}
+		return a.OpenTime.Before(c.OpenTime)
+	})
+	return candles
+}
+
+// StoreCandles stores the candles, merging each one into the stored candle of the
+// same bucket if there is one. Candles built from consecutive batches of swaps thus
+// add up to the candle of all the swaps, as long as each batch is stored once, e.g.
+// within the transaction of SwapFeed.Next.
+func StoreCandles(ctx context.Context, db *gorm.DB, candles []Candle) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, candle := range candles {
+			var stored Candle
+			result := tx.Where("token_name = ? AND pair = ? AND resolution = ? AND open_time = ?",
+				candle.TokenName, candle.Pair, candle.Resolution, candle.OpenTime.UTC()).
+				Limit(1).
+				Find(&stored)
+			if result.Error != nil {
+				return fmt.Errorf("failed to load candle: %v", result.Error)
+			}
+
+			stored.merge(candle)
+			stored.OpenTime = stored.OpenTime.UTC()
+			if err := tx.Save(&stored).Error; err != nil {
+				return fmt.Errorf("failed to store candle: %v", err)
+			}
+		}
+		return nil
+	})
+}
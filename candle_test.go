@@ -0,0 +1,120 @@
+package vwap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func candleSwap(t *testing.T, at time.Time, gnsAmount, usdcAmount string) ParsedSwap {
+	t.Helper()
+
+	swap, err := ParseSwap(Swap{
+		Time:         at.Format(time.RFC3339),
+		TokenA:       SwapToken{Symbol: "GNS"},
+		TokenAAmount: gnsAmount,
+		TokenB:       SwapToken{Symbol: "USDC"},
+		TokenBAmount: "-" + usdcAmount,
+		TotalUsd:     usdcAmount,
+	})
+	if err != nil {
+		t.Fatalf("invalid swap: %v", err)
+	}
+	return swap
+}
+
+func TestCandleBuilder(t *testing.T) {
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	noVolume := candleSwap(t, base.Add(70*time.Second), "10", "10")
+	noVolume.TotalUsd = Decimal{}
+
+	builder, err := NewCandleBuilder(Resolution1m, Resolution1h)
+	assert.NoError(t, err)
+	// added out of order: open and close follow the swap times
+	builder.Add(
+		candleSwap(t, base.Add(30*time.Second), "10", "30"), // 3
+		candleSwap(t, base.Add(10*time.Second), "10", "20"), // 2
+		candleSwap(t, base.Add(50*time.Second), "20", "20"), // 1
+		candleSwap(t, base.Add(90*time.Second), "10", "50"), // 5
+		noVolume,
+	)
+
+	var gns1m, gns1h, pair1h []Candle
+	for _, candle := range builder.Candles() {
+		switch {
+		case candle.TokenName == "GNS" && candle.Pair == "" && candle.Resolution == Resolution1m:
+			gns1m = append(gns1m, candle)
+		case candle.TokenName == "GNS" && candle.Pair == "" && candle.Resolution == Resolution1h:
+			gns1h = append(gns1h, candle)
+		case candle.Pair == NewPair("GNS", "USDC", 0).String() && candle.Resolution == Resolution1h:
+			pair1h = append(pair1h, candle)
+		}
+	}
+
+	if assert.Len(t, gns1m, 2) {
+		first := gns1m[0]
+		assert.Equal(t, base, first.OpenTime)
+		assert.Equal(t, "2", first.Open.String())
+		assert.Equal(t, "3", first.High.String())
+		assert.Equal(t, "1", first.Low.String())
+		assert.Equal(t, "1", first.Close.String())
+		assert.Equal(t, "70", first.Volume.String())
+		// (20*2 + 30*3 + 20*1) / 70
+		assert.True(t, NewDecimalFromInt(150).Quo(NewDecimalFromInt(70)).Equal(first.VWAP))
+		assert.Equal(t, 3, first.Trades)
+
+		assert.Equal(t, base.Add(time.Minute), gns1m[1].OpenTime)
+		assert.Equal(t, "5", gns1m[1].Close.String())
+	}
+
+	if assert.Len(t, gns1h, 1) {
+		assert.Equal(t, "2", gns1h[0].Open.String())
+		assert.Equal(t, "5", gns1h[0].Close.String())
+		assert.Equal(t, "120", gns1h[0].Volume.String())
+		assert.Equal(t, 4, gns1h[0].Trades)
+	}
+
+	// pairs are priced in quote per base with base volume, including swaps without USD value
+	if assert.Len(t, pair1h, 1) {
+		assert.Equal(t, "GNS", pair1h[0].TokenName)
+		assert.Equal(t, "60", pair1h[0].Volume.String())
+		assert.Equal(t, "5", pair1h[0].High.String())
+		assert.Equal(t, 5, pair1h[0].Trades)
+	}
+
+	_, err = NewCandleBuilder("2m")
+	assert.Error(t, err)
+}
+
+func TestStoreCandlesMergesBatches(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	store := func(swaps ...ParsedSwap) {
+		builder, err := NewCandleBuilder(Resolution1h)
+		assert.NoError(t, err)
+		builder.Add(swaps...)
+		assert.NoError(t, StoreCandles(context.Background(), db, builder.Candles()))
+	}
+	store(candleSwap(t, base.Add(10*time.Minute), "10", "20"))
+	store(candleSwap(t, base.Add(20*time.Minute), "10", "40"), candleSwap(t, base.Add(5*time.Minute), "10", "10"))
+
+	var candle Candle
+	assert.NoError(t, db.Where("token_name = ? AND pair = '' AND resolution = ?", "GNS", Resolution1h).First(&candle).Error)
+	assert.True(t, base.Equal(candle.OpenTime))
+	assert.Equal(t, "1", candle.Open.String())
+	assert.Equal(t, "4", candle.High.String())
+	assert.Equal(t, "1", candle.Low.String())
+	assert.Equal(t, "4", candle.Close.String())
+	assert.Equal(t, "70", candle.Volume.String())
+	// (10*1 + 20*2 + 40*4) / 70
+	assert.Equal(t, "3", candle.VWAP.String())
+	assert.Equal(t, 3, candle.Trades)
+
+	var count int64
+	db.Model(&Candle{}).Count(&count)
+	// GNS, USDC and the pair
+	assert.Equal(t, int64(3), count)
+}
@@ -0,0 +1,136 @@
+// Command backfill calculates the VWAP of past buckets from historical swaps.
+//
+// Re-running a range does not create duplicate rows:
+//
+//	backfill -from 2024-05-01T00:00:00Z -to 2024-05-02T00:00:00Z -interval 10m
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+func main() {
+	var (
+		from             = flag.String("from", "", "start of the range, RFC3339 or YYYY-MM-DD (required)")
+		to               = flag.String("to", "", "end of the range, RFC3339 or YYYY-MM-DD, defaults to now")
+		interval         = flag.Duration("interval", vwap.Window10m, "bucket size")
+		driver           = flag.String("driver", "sqlite", "database driver (mysql or sqlite)")
+		dsn              = flag.String("dsn", "vwap.db", "database DSN")
+		activityEndpoint = flag.String("activity-endpoint", vwap.ActivitySwapEndpoint, "Gnoswap activity endpoint")
+		swapsFile        = flag.String("swaps-file", "", "replay swaps from a JSON or CSV file instead of the API")
+		maxPages         = flag.Int("max-pages", 1000, "maximum number of activity pages to read")
+		aggregatorNames  = flag.String("aggregators", vwap.AggregatorVWAP, "comma-separated aggregators to compute (vwap, twap, ema, median)")
+		tokensFile       = flag.String("tokens", "", "token registry file (YAML or JSON)")
+		minVolume        = flag.String("min-volume", "0", "reject trades below this USD volume")
+		maxDeviation     = flag.String("max-deviation", "0", "reject trades deviating from the median price by more than this fraction")
+		maxTraderVolume  = flag.String("max-trader-volume", "0", "cap the USD volume a single trader may contribute to a token")
+		rejectSelfTrades = flag.Bool("reject-self-trades", false, "reject round trips of a trader buying and selling the same token")
+	)
+	flag.Parse()
+
+	if *from == "" {
+		log.Fatal("-from is required")
+	}
+	start, err := parseTime(*from)
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end := time.Now()
+	if *to != "" {
+		end, err = parseTime(*to)
+		if err != nil {
+			log.Fatalf("invalid -to: %v", err)
+		}
+	}
+
+	filterConfig := vwap.FilterConfig{RejectSelfTrades: *rejectSelfTrades}
+	for _, limit := range []struct {
+		name  string
+		value string
+		dest  *vwap.Decimal
+	}{
+		{"min-volume", *minVolume, &filterConfig.MinVolume},
+		{"max-deviation", *maxDeviation, &filterConfig.MaxDeviation},
+		{"max-trader-volume", *maxTraderVolume, &filterConfig.MaxTraderVolume},
+	} {
+		value, err := vwap.ParseDecimal(limit.value)
+		if err != nil {
+			log.Fatalf("invalid -%s: %v", limit.name, err)
+		}
+		*limit.dest = value
+	}
+	filter := vwap.NewTradeFilter(filterConfig)
+
+	var aggregators []vwap.Aggregator
+	for _, name := range strings.Split(*aggregatorNames, ",") {
+		aggregator, ok := vwap.AggregatorByName(strings.TrimSpace(name))
+		if !ok {
+			log.Fatalf("unknown aggregator: %s", name)
+		}
+		aggregators = append(aggregators, aggregator)
+	}
+
+	var registry *vwap.Registry
+	if *tokensFile != "" {
+		registry, err = vwap.LoadRegistry(*tokensFile)
+		if err != nil {
+			log.Fatalf("failed to load token registry: %v", err)
+		}
+	}
+
+	db, err := vwap.OpenDB(*driver, *dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := vwap.Migrate(context.Background(), db); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var swaps []vwap.ParsedSwap
+	if *swapsFile != "" {
+		raw, err := (&vwap.FileSource{SwapsPath: *swapsFile}).Swaps(ctx)
+		if err != nil {
+			log.Fatalf("failed to read swaps: %v", err)
+		}
+		var errs []error
+		swaps, errs = vwap.ParseSwaps(raw)
+		for _, err := range errs {
+			log.Printf("skipping swap: %v\n", err)
+		}
+	} else {
+		feed := vwap.NewSwapFeed(db, *activityEndpoint)
+		feed.MaxPages = *maxPages
+		feed.Registry = registry
+		swaps, err = feed.Range(ctx, start, end)
+		if err != nil {
+			log.Fatalf("failed to fetch swaps: %v", err)
+		}
+	}
+	log.Printf("backfilling %s to %s from %d swaps\n", start.Format(time.RFC3339), end.Format(time.RFC3339), len(swaps))
+
+	calculator := vwap.NewCalculator(db, nil, vwap.Config{Aggregators: aggregators, Registry: registry, Filter: filter})
+	n, err := calculator.Backfill(ctx, swaps, start, end, *interval)
+	if err != nil {
+		log.Fatalf("backfill failed after storing %d rows: %v", n, err)
+	}
+	log.Printf("stored %d rows\n", n)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
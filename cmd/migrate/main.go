@@ -0,0 +1,101 @@
+// Command migrate applies or reverts the schema migrations.
+//
+//	migrate [-driver sqlite] [-dsn vwap.db] up|down|status|to VERSION
+//
+// up applies every pending migration, down reverts the latest one, to migrates up or
+// down to the given version (0 reverts everything) and status lists the migrations.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+func main() {
+	var (
+		driver = flag.String("driver", "sqlite", "database driver (mysql or sqlite)")
+		dsn    = flag.String("dsn", "vwap.db", "database DSN")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] up|down|status|to VERSION\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := "up"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	db, err := vwap.OpenDB(*driver, *dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	migrations, err := vwap.Migrations(db.Dialector.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+	applied, err := vwap.AppliedMigrations(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var target int
+	switch command {
+	case "up":
+		if len(migrations) > 0 {
+			target = migrations[len(migrations)-1].Version
+		}
+	case "down":
+		if len(applied) == 0 {
+			log.Fatal("no migration to revert")
+		}
+		if len(applied) > 1 {
+			target = applied[len(applied)-2].Version
+		}
+	case "to":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		target, err = strconv.Atoi(flag.Arg(1))
+		if err != nil || target < 0 {
+			log.Fatalf("invalid version: %s", flag.Arg(1))
+		}
+	case "status":
+		appliedAt := make(map[int]string)
+		for _, migration := range applied {
+			appliedAt[migration.Version] = migration.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		for _, migration := range migrations {
+			status, ok := appliedAt[migration.Version]
+			if !ok {
+				status = "pending"
+			}
+			fmt.Printf("%04d_%s\t%s\n", migration.Version, migration.Name, status)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	n, err := vwap.MigrateTo(ctx, db, target)
+	if err != nil {
+		log.Fatalf("ran %d migrations before failing: %v", n, err)
+	}
+
+	version, err := vwap.SchemaVersion(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("ran %d migrations, schema version is %d\n", n, version)
+}
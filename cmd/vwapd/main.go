@@ -0,0 +1,175 @@
+// Command vwapd periodically calculates the VWAP of every token and stores the results.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gnoswap-labs/vwap"
+	"gorm.io/gorm"
+)
+
+func main() {
+	var (
+		interval         = flag.Duration("interval", vwap.Window10m, "calculation interval, aligned to wall-clock buckets")
+		driver           = flag.String("driver", "sqlite", "database driver (mysql or sqlite)")
+		dsn              = flag.String("dsn", "vwap.db", "database DSN")
+		activityEndpoint = flag.String("activity-endpoint", vwap.ActivitySwapEndpoint, "Gnoswap activity endpoint")
+		swapsFile        = flag.String("swaps-file", "", "replay swaps from a JSON or CSV file instead of the API")
+		httpAddr         = flag.String("http", "", "serve the VWAP query API on this address, e.g. :8080")
+		aggregatorNames  = flag.String("aggregators", vwap.AggregatorVWAP, "comma-separated aggregators to compute (vwap, twap, ema, median)")
+		minVolume        = flag.String("min-volume", "0", "reject trades below this USD volume")
+		maxDeviation     = flag.String("max-deviation", "0", "reject trades deviating from the median price by more than this fraction")
+		maxTraderVolume  = flag.String("max-trader-volume", "0", "cap the USD volume a single trader may contribute to a token")
+		rejectSelfTrades = flag.Bool("reject-self-trades", false, "reject round trips of a trader buying and selling the same token")
+		tokensFile       = flag.String("tokens", "", "token registry file (YAML or JSON), reloaded on SIGHUP")
+		retries          = flag.Int("retries", 3, "retries of a failed API request, with exponential backoff")
+		breakerThreshold = flag.Int("breaker-threshold", 5, "consecutive failed API requests that pause calls to the API")
+		breakerCooldown  = flag.Duration("breaker-cooldown", time.Minute, "how long calls to the API are paused, serving last known prices")
+	)
+	flag.Parse()
+
+	filterConfig := vwap.FilterConfig{RejectSelfTrades: *rejectSelfTrades}
+	for _, limit := range []struct {
+		name  string
+		value string
+		dest  *vwap.Decimal
+	}{
+		{"min-volume", *minVolume, &filterConfig.MinVolume},
+		{"max-deviation", *maxDeviation, &filterConfig.MaxDeviation},
+		{"max-trader-volume", *maxTraderVolume, &filterConfig.MaxTraderVolume},
+	} {
+		value, err := vwap.ParseDecimal(limit.value)
+		if err != nil {
+			log.Fatalf("invalid -%s: %v", limit.name, err)
+		}
+		*limit.dest = value
+	}
+	filter := vwap.NewTradeFilter(filterConfig)
+
+	var aggregators []vwap.Aggregator
+	for _, name := range strings.Split(*aggregatorNames, ",") {
+		aggregator, ok := vwap.AggregatorByName(strings.TrimSpace(name))
+		if !ok {
+			log.Fatalf("unknown aggregator: %s", name)
+		}
+		aggregators = append(aggregators, aggregator)
+	}
+
+	db, err := vwap.OpenDB(*driver, *dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := vwap.Migrate(context.Background(), db); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	if *retries == 0 {
+		*retries = -1
+	}
+	client := vwap.NewClient(vwap.ClientConfig{
+		MaxRetries:       *retries,
+		FailureThreshold: *breakerThreshold,
+		Cooldown:         *breakerCooldown,
+	})
+
+	var source vwap.TradeSource = &vwap.GnoswapSource{
+		ActivityEndpoint: *activityEndpoint,
+		Client:           client,
+	}
+	// pair VWAPs and candles are calculated from the swaps added to the activity feed
+	// since the last tick, which are also recorded for the token VWAPs
+	var feed *vwap.SwapFeed
+	if *swapsFile != "" {
+		source = &vwap.FileSource{SwapsPath: *swapsFile}
+	} else {
+		feed = vwap.NewSwapFeed(db, *activityEndpoint)
+		feed.Window = *interval
+		feed.Client = client
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var registry *vwap.Registry
+	if *tokensFile != "" {
+		registry, err = vwap.LoadRegistry(*tokensFile)
+		if err != nil {
+			log.Fatalf("failed to load token registry: %v", err)
+		}
+		registry.WatchSIGHUP(ctx)
+		if feed != nil {
+			feed.Registry = registry
+		}
+	}
+
+	calculator := vwap.NewCalculator(db, source, vwap.Config{Aggregators: aggregators, Filter: filter, Registry: registry, Window: *interval})
+	if err := calculator.RestoreLastPrices(ctx); err != nil {
+		log.Fatalf("failed to restore last prices: %v", err)
+	}
+
+	scheduler := vwap.NewScheduler(*interval, func(ctx context.Context, tick time.Time) {
+		// the swaps of the feed are processed first, so that VWAP also prices the
+		// swaps beyond the latest page served by the source
+		if feed != nil {
+			n, err := feed.Next(ctx, func(ctx context.Context, tx *gorm.DB, swaps []vwap.ParsedSwap) error {
+				calculator.AddSwaps(swaps)
+				if _, err := calculator.PairVWAPTx(ctx, tx, swaps); err != nil {
+					return err
+				}
+				candles, err := vwap.NewCandleBuilder()
+				if err != nil {
+					return err
+				}
+				candles.Add(swaps...)
+				return vwap.StoreCandles(ctx, tx, candles.Candles())
+			})
+			if err != nil {
+				log.Printf("tick %s: failed to process swaps: %v\n", tick.Format(time.RFC3339), err)
+			} else {
+				log.Printf("tick %s: processed %d new swaps\n", tick.Format(time.RFC3339), n)
+			}
+		}
+
+		result, err := calculator.VWAP(ctx)
+		if err != nil {
+			log.Printf("tick %s failed: %v\n", tick.Format(time.RFC3339), err)
+			return
+		}
+		log.Printf("tick %s: run %s stored VWAP for %d tokens, skipped %d\n", tick.Format(time.RFC3339), result.RunID, result.Count(vwap.TokenSuccess), result.Count(vwap.TokenSkipped))
+		if err := result.Err(); err != nil {
+			log.Printf("tick %s: %v\n", tick.Format(time.RFC3339), err)
+		}
+	})
+
+	var server *http.Server
+	if *httpAddr != "" {
+		server = &http.Server{Addr: *httpAddr, Handler: vwap.NewServer(db)}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("http server failed: %v\n", err)
+				stop()
+			}
+		}()
+	}
+
+	log.Printf("vwapd started, interval %s\n", *interval)
+	scheduler.Run(ctx)
+
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shut down http server: %v\n", err)
+		}
+	}
+	log.Println("vwapd stopped")
+}
@@ -0,0 +1,224 @@
+package vwap
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DecimalScale is the number of fractional digits kept when a Decimal
+// is persisted or formatted.
+const DecimalScale = 18
+
+// Decimal is an exact decimal number backed by a big rational.
+// Arithmetic never rounds; rounding only happens when the value is formatted
+// or persisted with DecimalScale fractional digits.
+//
+// The zero value is 0 and Decimals are immutable, so they can be shared freely.
+type Decimal struct {
+	rat *big.Rat
+}
+
+// ParseDecimal parses a decimal string such as "15043681760.213797" or "-1e-6".
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.Contains(s, "/") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{rat: r}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics if s is not a valid decimal.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func NewDecimalFromInt(i int64) Decimal {
+	return Decimal{rat: new(big.Rat).SetInt64(i)}
+}
+
+// NewDecimalFromBigInt returns a Decimal holding the value of i.
+func NewDecimalFromBigInt(i *big.Int) Decimal {
+	if i == nil {
+		return Decimal{}
+	}
+	return Decimal{rat: new(big.Rat).SetInt(i)}
+}
+
+// NewDecimalFromFloat returns the exact value of f.
+// It should only be used where the input is already a float, never for parsing.
+func NewDecimalFromFloat(f float64) Decimal {
+	r := new(big.Rat)
+	if r.SetFloat64(f) == nil {
+		return Decimal{}
+	}
+	return Decimal{rat: r}
+}
+
+// NewDecimalFromRat returns a Decimal holding a copy of r.
+func NewDecimalFromRat(r *big.Rat) Decimal {
+	if r == nil {
+		return Decimal{}
+	}
+	return Decimal{rat: new(big.Rat).Set(r)}
+}
+
+func (d Decimal) get() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return d.rat
+}
+
+// Rat returns a copy of the underlying rational.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).Set(d.get())
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Add(d.get(), o.get())}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Sub(d.get(), o.get())}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Mul(d.get(), o.get())}
+}
+
+// Quo returns d / o. It panics if o is zero.
+func (d Decimal) Quo(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Quo(d.get(), o.get())}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{rat: new(big.Rat).Neg(d.get())}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{rat: new(big.Rat).Abs(d.get())}
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	return d.get().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compares d and o and returns -1, 0 or +1.
+func (d Decimal) Cmp(o Decimal) int {
+	return d.get().Cmp(o.get())
+}
+
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// Float64 returns the nearest float64 value of d.
+func (d Decimal) Float64() float64 {
+	f, _ := d.get().Float64()
+	return f
+}
+
+// StringFixed formats d with exactly places fractional digits, rounding half away from zero.
+func (d Decimal) StringFixed(places int) string {
+	return d.get().FloatString(places)
+}
+
+// String formats d with up to DecimalScale fractional digits and no trailing zeros.
+func (d Decimal) String() string {
+	s := d.StringFixed(DecimalScale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts both quoted decimal strings and JSON numbers.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value implements driver.Valuer. Values are stored as decimal strings
+// rounded to DecimalScale fractional digits.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.StringFixed(DecimalScale), nil
+}
+
+// Scan implements sql.Scanner.
+func (d *Decimal) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case string:
+		return d.scanString(v)
+	case []byte:
+		return d.scanString(string(v))
+	case int64:
+		*d = NewDecimalFromInt(v)
+		return nil
+	case float64:
+		return d.scanString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", src)
+	}
+}
+
+func (d *Decimal) scanString(s string) error {
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// GormDBDataType picks an exact column type for each dialect.
+// SQLite has no exact decimal type, so the value is kept as text there.
+func (Decimal) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return fmt.Sprintf("DECIMAL(38,%d)", DecimalScale)
+	case "postgres":
+		return fmt.Sprintf("NUMERIC(38,%d)", DecimalScale)
+	default:
+		return "TEXT"
+	}
+}
@@ -0,0 +1,87 @@
+package vwap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"15043681760.213797", "15043681760.213797", false},
+		{"0.000000", "0", false},
+		{"-685659", "-685659", false},
+		{"1e-6", "0.000001", false},
+		{" 30.087345468020313 ", "30.087345468020313", false},
+		{"", "", true},
+		{"1/3", "", true},
+		{"invalid", "", true},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.input)
+		if tt.wantErr {
+			assert.Error(t, err, "input %q", tt.input)
+			continue
+		}
+		assert.NoError(t, err, "input %q", tt.input)
+		assert.Equal(t, tt.expected, d.String(), "input %q", tt.input)
+	}
+}
+
+func TestDecimalArithmeticIsExact(t *testing.T) {
+	// 0.1 + 0.2 drifts with float64
+	sum := MustParseDecimal("0.1").Add(MustParseDecimal("0.2"))
+	assert.True(t, sum.Equal(MustParseDecimal("0.3")))
+
+	third := NewDecimalFromInt(1).Quo(NewDecimalFromInt(3))
+	assert.True(t, third.Mul(NewDecimalFromInt(3)).Equal(NewDecimalFromInt(1)))
+	assert.Equal(t, "0.333333333333333333", third.String())
+	assert.Equal(t, "0.33", third.StringFixed(2))
+
+	var zero Decimal
+	assert.True(t, zero.IsZero())
+	assert.Equal(t, "0", zero.String())
+	assert.Equal(t, "-1.5", zero.Sub(MustParseDecimal("1.5")).String())
+	assert.Equal(t, "1.5", MustParseDecimal("-1.5").Abs().String())
+}
+
+func TestDecimalJSON(t *testing.T) {
+	data, err := json.Marshal(MustParseDecimal("30.087345"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"30.087345"`, string(data))
+
+	var d Decimal
+	assert.NoError(t, json.Unmarshal([]byte(`"1.25"`), &d))
+	assert.Equal(t, "1.25", d.String())
+
+	assert.NoError(t, json.Unmarshal([]byte(`2.5`), &d))
+	assert.Equal(t, "2.5", d.String())
+
+	assert.Error(t, json.Unmarshal([]byte(`"abc"`), &d))
+}
+
+func TestDecimalScanAndValue(t *testing.T) {
+	value, err := MustParseDecimal("1.5").Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.500000000000000000", value)
+
+	var d Decimal
+	for _, src := range []any{"1.5", []byte("1.5"), 1.5} {
+		assert.NoError(t, d.Scan(src))
+		assert.Equal(t, "1.5", d.String())
+	}
+
+	assert.NoError(t, d.Scan(int64(7)))
+	assert.Equal(t, "7", d.String())
+
+	assert.NoError(t, d.Scan(nil))
+	assert.True(t, d.IsZero())
+
+	assert.Error(t, d.Scan(true))
+}
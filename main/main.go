@@ -2,194 +2,73 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"sort"
 	"time"
-)
 
-type Transaction struct {
-	id         string
-	token0Path string
-	token1Path string
-	amount0    int
-	amount1    int
-	time       time.Time
-}
+	"github.com/gnoswap-labs/vwap"
+	"github.com/gnoswap-labs/vwap/pricing"
+)
 
 func main() {
 	layout := "2006-01-02 15:04:05"
-	transactions := []Transaction{
-		{"ccb4668d", "gno.land/r/demo/gns", "gno.land/r/demo/wugnot", 100000, -685659, parseTime("2024-05-16 05:21:17", layout)},
-		{"58f51962", "gno.land/r/demo/gns", "gno.land/r/demo/wugnot", 10000, -68658, parseTime("2024-05-16 05:20:34", layout)},
-		{"b4c2a9c0", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 27000000, -18399281, parseTime("2024-05-16 05:15:00", layout)},
-		{"b8a0ad7d", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 2000000, -3771726, parseTime("2024-05-16 05:14:17", layout)},
-		{"65d7ad35", "gno.land/r/demo/bar", "gno.land/r/demo/gns", 1000000, -131195131, parseTime("2024-05-16 05:05:14", layout)},
-		{"c06cdf98", "gno.land/r/demo/gns", "gno.land/r/demo/bar", 10000, -19961, parseTime("2024-05-16 05:04:51", layout)},
-		{"792098bf", "gno.land/r/demo/baz", "gno.land/r/demo/gns", 1000000, -6437928, parseTime("2024-05-16 04:42:41", layout)},
-		{"6d07c81c", "gno.land/r/demo/foo", "gno.land/r/demo/gns", 50000, -96865, parseTime("2024-05-16 02:01:28", layout)},
-		{"a16085c3", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 245, -242450006, parseTime("2024-05-14 14:29:22", layout)},
-		{"389b0fa9", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 2, -9985, parseTime("2024-05-14 14:28:47", layout)},
+	transactions := []pricing.Transaction{
+		newTransaction("ccb4668d", "gno.land/r/demo/gns", "gno.land/r/demo/wugnot", 100000, -685659, parseTime("2024-05-16 05:21:17", layout)),
+		newTransaction("58f51962", "gno.land/r/demo/gns", "gno.land/r/demo/wugnot", 10000, -68658, parseTime("2024-05-16 05:20:34", layout)),
+		newTransaction("b4c2a9c0", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 27000000, -18399281, parseTime("2024-05-16 05:15:00", layout)),
+		newTransaction("b8a0ad7d", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 2000000, -3771726, parseTime("2024-05-16 05:14:17", layout)),
+		newTransaction("65d7ad35", "gno.land/r/demo/bar", "gno.land/r/demo/gns", 1000000, -131195131, parseTime("2024-05-16 05:05:14", layout)),
+		newTransaction("c06cdf98", "gno.land/r/demo/gns", "gno.land/r/demo/bar", 10000, -19961, parseTime("2024-05-16 05:04:51", layout)),
+		newTransaction("792098bf", "gno.land/r/demo/baz", "gno.land/r/demo/gns", 1000000, -6437928, parseTime("2024-05-16 04:42:41", layout)),
+		newTransaction("6d07c81c", "gno.land/r/demo/foo", "gno.land/r/demo/gns", 50000, -96865, parseTime("2024-05-16 02:01:28", layout)),
+		newTransaction("a16085c3", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 245, -242450006, parseTime("2024-05-14 14:29:22", layout)),
+		newTransaction("389b0fa9", "gno.land/r/demo/wugnot", "gno.land/r/demo/gns", 2, -9985, parseTime("2024-05-14 14:28:47", layout)),
 	}
 
-	sort.Slice(transactions, func(i, j int) bool {
-		return transactions[i].time.Before(transactions[j].time)
-	})
-
-	priceMap := make(map[string]float64)
-	priceMap["gno.land/r/demo/wugnot"] = 1.0
-	priceMap["gno.land/r/demo/gns"] = 0.0
-	priceMap["gno.land/r/demo/bar"] = 0.0
-	priceMap["gno.land/r/demo/baz"] = 0.0
-	priceMap["gno.land/r/demo/foo"] = 0.0
+	priceMap := map[string]vwap.Decimal{
+		pricing.WUGNOT: vwap.NewDecimalFromInt(1),
+	}
 
-	priceHistory := calculatePriceHistory(transactions, priceMap)
-	volumeHistory := calculateVolumeHistory(transactions)
+	registry := vwap.DefaultRegistry()
+	priceHistory := pricing.PriceHistory(transactions, priceMap, registry, pricing.DefaultInterval)
+	volumeHistory := pricing.VolumeHistory(transactions, registry, pricing.DefaultInterval)
 
 	for i := 0; i < len(priceHistory); i++ {
 		entry := priceHistory[i]
 		volumeEntry := volumeHistory[i]
 
-		fmt.Printf("Time: %s\n", entry.time.Format(layout))
-		for token, price := range entry.prices {
-			volume := volumeEntry.volumes[token]
-			vwap := calculateVWAP(token, transactions, entry.time)
-			fmt.Printf("%s: $%.4f, Volume: %d, VWAP: $%.4f\n", token, price, volume, vwap)
+		fmt.Printf("Time: %s\n", entry.Time.Format(layout))
+		for _, token := range sortedKeys(entry.Prices) {
+			price := entry.Prices[token]
+			volume := volumeEntry.Volumes[token]
+			vwapPrice, _ := pricing.VWAP(token, transactions, priceMap, registry, entry.Time, entry.Time.Add(pricing.DefaultInterval))
+			fmt.Printf("%s: $%s, Volume: %s, VWAP: $%s\n", token, price.StringFixed(4), volume, vwapPrice.StringFixed(4))
 		}
 		fmt.Println("-----------")
 	}
 }
 
-func parseTime(timeStr, layout string) time.Time {
-	t, _ := time.Parse(layout, timeStr)
-	return t
-}
-
-func calculatePriceHistory(transactions []Transaction, initialPrices map[string]float64) []PriceEntry {
-	var priceHistory []PriceEntry
-	currentPrices := make(map[string]float64)
-	for token, price := range initialPrices {
-		currentPrices[token] = price
-	}
-
-	if len(transactions) == 0 {
-		return priceHistory
-	}
-
-	start := roundTimeToNearestTenMinutes(transactions[0].time)
-
-	lastIndex := len(transactions) - 1
-	for current := start; current.Before(transactions[lastIndex].time.Add(10 * time.Minute)); current = current.Add(10 * time.Minute) {
-		for _, tx := range transactions {
-			if tx.time.Before(current.Add(10 * time.Minute)) {
-				updatePrices(tx, currentPrices)
-			}
-		}
-		priceHistory = append(priceHistory, PriceEntry{time: current, prices: copyMap(currentPrices)})
+func newTransaction(id, token0Path, token1Path string, amount0, amount1 int64, t time.Time) pricing.Transaction {
+	return pricing.Transaction{
+		ID:         id,
+		Token0Path: token0Path,
+		Token1Path: token1Path,
+		Amount0:    big.NewInt(amount0),
+		Amount1:    big.NewInt(amount1),
+		Time:       t,
 	}
-
-	return priceHistory
 }
 
-// use cross rate to update prices
-func updatePrices(tx Transaction, prices map[string]float64) {
-    var exchangeRate float64
-    if tx.token0Path == "gno.land/r/demo/wugnot" {
-        exchangeRate = float64(tx.amount0) / float64(-tx.amount1)
-        prices[tx.token1Path] = 1 / exchangeRate
-    } else if tx.token1Path == "gno.land/r/demo/wugnot" {
-        exchangeRate = float64(-tx.amount1) / float64(tx.amount0)
-        prices[tx.token0Path] = exchangeRate
-    }
-
-    if prices["gno.land/r/demo/gns"] != 0 {
-        if tx.token0Path == "gno.land/r/demo/gns" {
-            exchangeRate = float64(tx.amount0) / float64(-tx.amount1)
-            if _, exists := prices[tx.token1Path]; !exists {
-                prices[tx.token1Path] = prices["gno.land/r/demo/gns"] / exchangeRate
-            }
-        } else if tx.token1Path == "gno.land/r/demo/gns" {
-            exchangeRate = float64(-tx.amount1) / float64(tx.amount0)
-            if _, exists := prices[tx.token0Path]; !exists {
-                prices[tx.token0Path] = prices["gno.land/r/demo/gns"] * exchangeRate
-            }
-        }
-    }
-}
-
-func calculateVolumeHistory(transactions []Transaction) []VolumeEntry {
-	var volumeHistory []VolumeEntry
-	currentVolumes := make(map[string]int)
-
-	start := roundTimeToNearestTenMinutes(transactions[0].time)
-
-	lastIndex := len(transactions) - 1
-	for current := start; current.Before(transactions[lastIndex].time.Add(10 * time.Minute)); current = current.Add(10 * time.Minute) {
-		for _, tx := range transactions {
-			if tx.time.Before(current.Add(10 * time.Minute)) {
-				currentVolumes[tx.token0Path] += tx.amount0
-				currentVolumes[tx.token1Path] += -tx.amount1
-			}
-		}
-		volumeHistory = append(volumeHistory, VolumeEntry{time: current, volumes: copyIntMap(currentVolumes)})
-	}
-
-	return volumeHistory
-}
-
-func calculateVWAP(token string, transactions []Transaction, currentTime time.Time) float64 {
-    if token == "gno.land/r/demo/wugnot" {
-        return 1.0
-    }
-
-    var totalValue float64
-    var totalVolume int
-
-    for _, tx := range transactions {
-        if tx.time.Before(currentTime.Add(10 * time.Minute)) {
-            if tx.token0Path == token {
-                totalValue += float64(tx.amount0) * float64(-tx.amount1) / float64(tx.amount0)
-                totalVolume += tx.amount0
-            } else if tx.token1Path == token {
-                totalValue += float64(-tx.amount1) * float64(tx.amount0) / float64(-tx.amount1)
-                totalVolume += -tx.amount1
-            }
-        }
-    }
-
-    if totalVolume == 0 {
-        return 0
-    }
-
-    return totalValue / float64(totalVolume)
-}
-
-func roundTimeToNearestTenMinutes(t time.Time) time.Time {
-	minute := t.Minute()
-	roundedMinute := minute - minute%10
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), roundedMinute, 0, 0, t.Location())
-}
-
-func copyMap(original map[string]float64) map[string]float64 {
-	newMap := make(map[string]float64)
-	for key, value := range original {
-		newMap[key] = value
-	}
-	return newMap
+func parseTime(timeStr, layout string) time.Time {
+	t, _ := time.Parse(layout, timeStr)
+	return t
 }
 
-func copyIntMap(original map[string]int) map[string]int {
-	newMap := make(map[string]int)
-	for key, value := range original {
-		newMap[key] = value
+func sortedKeys(m map[string]vwap.Decimal) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-	return newMap
-}
-
-type PriceEntry struct {
-	time   time.Time
-	prices map[string]float64
-}
-
-type VolumeEntry struct {
-	time    time.Time
-	volumes map[string]int
+	sort.Strings(keys)
+	return keys
 }
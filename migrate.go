@@ -0,0 +1,255 @@
+package vwap
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The schema is defined by the scripts in migrations/<dialect>, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. A new change of the schema
+// is a new pair of scripts for every dialect; applied scripts must not be edited.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrLegacySchema is returned when a migration would create a table that already
+// exists, e.g. one created by an earlier version without migrations. Such tables
+// are not adopted, since their columns differ from the ones of the migrations.
+var ErrLegacySchema = errors.New("table exists but was not created by a migration")
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration records an applied migration in the schema_migrations table.
+type SchemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at DATETIME NOT NULL
+)`
+
+// Migrations returns the migrations of the dialect ("mysql" or "sqlite"), oldest first.
+func Migrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported migration dialect: %s", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || !strings.HasSuffix(name, ".sql") || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		number, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", name)
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", name, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: title}
+			byVersion[version] = migration
+		} else if migration.Name != title {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, title)
+		}
+		if direction == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// AppliedMigrations returns the migrations applied to the database, oldest first.
+func AppliedMigrations(ctx context.Context, db *gorm.DB) ([]SchemaMigration, error) {
+	db = db.WithContext(ctx)
+	if err := db.Exec(createSchemaMigrations).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	var applied []SchemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	return applied, nil
+}
+
+// SchemaVersion returns the version of the latest applied migration, or 0 if none is.
+func SchemaVersion(ctx context.Context, db *gorm.DB) (int, error) {
+	applied, err := AppliedMigrations(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// Migrate applies every pending migration. It returns the number of migrations applied.
+func Migrate(ctx context.Context, db *gorm.DB) (int, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return MigrateTo(ctx, db, migrations[len(migrations)-1].Version)
+}
+
+// MigrateTo applies the pending migrations up to version, then reverts the applied
+// migrations above it, newest first. It returns the number of migrations run.
+//
+// Each migration runs in a transaction with the update of schema_migrations.
+// MySQL commits DDL statements implicitly though, so a migration failing there
+// may have to be cleaned up by hand.
+func MigrateTo(ctx context.Context, db *gorm.DB, version int) (int, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := AppliedMigrations(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	known := make(map[int]Migration)
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+	isApplied := make(map[int]bool)
+	for _, migration := range applied {
+		if _, ok := known[migration.Version]; !ok {
+			return 0, fmt.Errorf("database has unknown migration %d_%s, upgrade the binary", migration.Version, migration.Name)
+		}
+		isApplied[migration.Version] = true
+	}
+
+	n := 0
+	for _, migration := range migrations {
+		if migration.Version > version || isApplied[migration.Version] {
+			continue
+		}
+		if err := checkNewTables(db.WithContext(ctx), migration); err != nil {
+			return n, err
+		}
+		if err := runMigration(ctx, db, migration, true); err != nil {
+			return n, err
+		}
+		n++
+	}
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].Version <= version {
+			break
+		}
+		if err := runMigration(ctx, db, known[applied[i].Version], false); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+var createTablePattern = regexp.MustCompile(`(?i)CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+
+// checkNewTables fails with ErrLegacySchema if a table created by the up script of
+// the migration exists already.
+func checkNewTables(db *gorm.DB, migration Migration) error {
+	for _, match := range createTablePattern.FindAllStringSubmatch(migration.Up, -1) {
+		if db.Migrator().HasTable(match[1]) {
+			return fmt.Errorf("migration %d_%s: table %s: %w; migrate its rows to a new database instead", migration.Version, migration.Name, match[1], ErrLegacySchema)
+		}
+	}
+	return nil
+}
+
+func runMigration(ctx context.Context, db *gorm.DB, migration Migration, up bool) error {
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		}
+		return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %v", migration.Version, migration.Name, direction, err)
+	}
+	return nil
+}
+
+// splitStatements splits a script into statements, each ending with a semicolon at
+// the end of a line. Lines starting with -- are comments.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
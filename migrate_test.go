@@ -0,0 +1,126 @@
+package vwap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	migrations, err := Migrations("sqlite")
+	assert.NoError(t, err)
+	latest := migrations[len(migrations)-1].Version
+
+	n, err := Migrate(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), n)
+
+	version, err := SchemaVersion(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, version)
+	for _, table := range []string{"vwap_data", "swap_cursors", "candles"} {
+		assert.True(t, db.Migrator().HasTable(table), table)
+	}
+	assert.True(t, db.Migrator().HasColumn(&VWAPData{}, "run_id"))
+
+	// nothing left to apply
+	n, err = Migrate(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// revert the latest migration, then everything
+	n, err = MigrateTo(ctx, db, latest-1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.False(t, db.Migrator().HasColumn(&VWAPData{}, "run_id"))
+	assert.True(t, db.Migrator().HasTable("vwap_data"))
+
+	n, err = MigrateTo(ctx, db, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations)-1, n)
+	assert.False(t, db.Migrator().HasTable("vwap_data"))
+
+	version, err = SchemaVersion(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	// and back up
+	n, err = Migrate(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), n)
+}
+
+func TestMigrateRejectsLegacyTables(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	// the table as created by AutoMigrate before migrations existed
+	assert.NoError(t, db.Exec(`CREATE TABLE vwap_data (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME, updated_at DATETIME, deleted_at DATETIME,
+		token_name TEXT, vwap REAL, calculated_at DATETIME)`).Error)
+
+	n, err := Migrate(ctx, db)
+	assert.ErrorIs(t, err, ErrLegacySchema)
+	assert.Equal(t, 0, n)
+
+	version, err := SchemaVersion(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.False(t, db.Migrator().HasColumn("vwap_data", "pair"))
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	sqliteMigrations, err := Migrations("sqlite")
+	assert.NoError(t, err)
+	mysqlMigrations, err := Migrations("mysql")
+	assert.NoError(t, err)
+
+	if assert.Equal(t, len(sqliteMigrations), len(mysqlMigrations)) {
+		for i := range sqliteMigrations {
+			assert.Equal(t, sqliteMigrations[i].Version, mysqlMigrations[i].Version)
+			assert.Equal(t, sqliteMigrations[i].Name, mysqlMigrations[i].Name)
+		}
+	}
+
+	_, err = Migrations("postgres")
+	assert.Error(t, err)
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- a comment
+CREATE TABLE a (
+    id INTEGER
+);
+
+CREATE INDEX idx_a ON a (id);
+DROP TABLE b`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE a (\n    id INTEGER\n)",
+		"CREATE INDEX idx_a ON a (id)",
+		"DROP TABLE b",
+	}, splitStatements(script))
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS vwap_data (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    token_name VARCHAR(255) NOT NULL,
+    pair VARCHAR(255) NOT NULL DEFAULT '',
+    aggregator VARCHAR(20) NOT NULL DEFAULT 'vwap',
+    window_size VARCHAR(10) NOT NULL DEFAULT '10m',
+    vwap DECIMAL(38, 18) NOT NULL,
+    total_volume DECIMAL(38, 18) NOT NULL,
+    calculated_at DATETIME(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT '',
+    UNIQUE KEY idx_vwap_bucket (token_name, pair, aggregator, window_size, calculated_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS swap_cursors (
+    source VARCHAR(255) NOT NULL PRIMARY KEY,
+    last_time DATETIME(3) NULL,
+    last_tx_hashes TEXT NOT NULL,
+    updated_at DATETIME(3) NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS candles (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    token_name VARCHAR(255) NOT NULL,
+    pair VARCHAR(255) NOT NULL DEFAULT '',
+    resolution VARCHAR(10) NOT NULL,
+    open_time DATETIME(3) NOT NULL,
+    open DECIMAL(38, 18) NOT NULL,
+    high DECIMAL(38, 18) NOT NULL,
+    low DECIMAL(38, 18) NOT NULL,
+    close DECIMAL(38, 18) NOT NULL,
+    volume DECIMAL(38, 18) NOT NULL,
+    vwap DECIMAL(38, 18) NOT NULL,
+    trades BIGINT NOT NULL DEFAULT 0,
+    first_trade_at DATETIME(3) NULL,
+    last_trade_at DATETIME(3) NULL,
+    UNIQUE KEY idx_candle (token_name, pair, resolution, open_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
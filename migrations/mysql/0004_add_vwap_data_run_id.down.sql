@@ -0,0 +1,3 @@
+ALTER TABLE vwap_data
+    DROP INDEX idx_vwap_data_run_id,
+    DROP COLUMN run_id;
@@ -0,0 +1,3 @@
+ALTER TABLE vwap_data
+    ADD COLUMN run_id VARCHAR(32) NOT NULL DEFAULT '' AFTER status,
+    ADD INDEX idx_vwap_data_run_id (run_id);
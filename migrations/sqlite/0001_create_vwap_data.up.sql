@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS vwap_data (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    token_name VARCHAR(255) NOT NULL,
+    pair VARCHAR(255) NOT NULL DEFAULT '',
+    aggregator VARCHAR(20) NOT NULL DEFAULT 'vwap',
+    window_size VARCHAR(10) NOT NULL DEFAULT '10m',
+    vwap TEXT NOT NULL,
+    total_volume TEXT NOT NULL,
+    calculated_at DATETIME NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vwap_bucket ON vwap_data (token_name, pair, aggregator, window_size, calculated_at);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS swap_cursors (
+    source VARCHAR(255) PRIMARY KEY,
+    last_time DATETIME,
+    last_tx_hashes TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME
+);
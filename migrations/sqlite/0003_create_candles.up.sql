@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS candles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    token_name VARCHAR(255) NOT NULL,
+    pair VARCHAR(255) NOT NULL DEFAULT '',
+    resolution VARCHAR(10) NOT NULL,
+    open_time DATETIME NOT NULL,
+    open TEXT NOT NULL,
+    high TEXT NOT NULL,
+    low TEXT NOT NULL,
+    close TEXT NOT NULL,
+    volume TEXT NOT NULL,
+    vwap TEXT NOT NULL,
+    trades INTEGER NOT NULL DEFAULT 0,
+    first_trade_at DATETIME,
+    last_trade_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_candle ON candles (token_name, pair, resolution, open_time);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_vwap_data_run_id;
+
+ALTER TABLE vwap_data DROP COLUMN run_id;
@@ -0,0 +1,3 @@
+ALTER TABLE vwap_data ADD COLUMN run_id VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_vwap_data_run_id ON vwap_data (run_id);
@@ -0,0 +1,185 @@
+package vwap
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pair identifies a pool by its two tokens and fee tier, in the same format as
+// TokenPrice.MostLiquidityPool, e.g. "gno.land/r/demo/bar:gno.land/r/demo/baz:100".
+//
+// Like Gnoswap pool paths, pairs are canonical: Base sorts before Quote, so both
+// swap directions of a pool map to the same Pair. Prices of a pair are expressed
+// as an amount of Quote per unit of Base.
+type Pair struct {
+	Base  string
+	Quote string
+	Fee   int
+}
+
+// NewPair returns the canonical pair of the two tokens.
+func NewPair(tokenA, tokenB string, fee int) Pair {
+	if tokenB < tokenA {
+		tokenA, tokenB = tokenB, tokenA
+	}
+	return Pair{Base: tokenA, Quote: tokenB, Fee: fee}
+}
+
+// ParsePair parses a pool key such as "bar:baz:100". The fee tier may be omitted.
+func ParsePair(s string) (Pair, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Pair{}, fmt.Errorf("invalid pair %q", s)
+	}
+
+	fee := 0
+	if len(parts) == 3 {
+		parsed, err := strconv.Atoi(parts[2])
+		if err != nil || parsed < 0 {
+			return Pair{}, fmt.Errorf("invalid fee tier in pair %q", s)
+		}
+		fee = parsed
+	}
+
+	return NewPair(parts[0], parts[1], fee), nil
+}
+
+// IsZero reports whether p is the zero Pair.
+func (p Pair) IsZero() bool {
+	return p == Pair{}
+}
+
+// String returns the pool key of p.
+func (p Pair) String() string {
+	if p.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s:%d", p.Base, p.Quote, p.Fee)
+}
+
+// PairVWAP calculates and stores the VWAP of every pair traded in the swaps, after
+// dropping the trades rejected by the configured filter.
+// Each swap is priced in the bucket of the window it was made in, and a bucket
+// stored by an earlier call is merged with the new swaps, like StoreCandles does,
+// so that swaps read in consecutive batches add up. Each batch must be stored once,
+// see PairVWAPTx. It returns the price of the latest bucket of each pair.
+// Swaps do not carry the pool fee tier, so pairs built from them have a zero fee.
+// The prices are stored in a single transaction: if storing fails, none is stored.
+func (c *Calculator) PairVWAP(ctx context.Context, swaps []ParsedSwap) (map[Pair]Decimal, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+	return c.PairVWAPTx(ctx, c.db, swaps)
+}
+
+// PairVWAPTx is PairVWAP reading and writing through tx, e.g. the transaction of
+// SwapFeed.Next, so that the merged buckets are committed with the cursor.
+func (c *Calculator) PairVWAPTx(ctx context.Context, tx *gorm.DB, swaps []ParsedSwap) (map[Pair]Decimal, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("db is nil")
+	}
+
+	type pairBucket struct {
+		pair Pair
+		end  time.Time
+	}
+	trades := make(map[pairBucket][]TradeData)
+	for _, swap := range swaps {
+		trade := swapToPairTrade(swap)
+		bucket := pairBucket{pair: trade.Pair, end: bucketEnd(swap.Time.UTC(), c.window())}
+		trades[bucket] = append(trades[bucket], trade)
+	}
+
+	type pricedBucket struct {
+		pair Pair
+		row  VWAPData
+	}
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		priced []pricedBucket
+	)
+
+	for bucket, tradeData := range trades {
+		wg.Add(1)
+		go func(bucket pairBucket, tradeData []TradeData) {
+			defer wg.Done()
+			key := seriesKey{tokenName: bucket.pair.Base, pair: bucket.pair.String(), aggregator: AggregatorVWAP}
+			tradeData = c.config.Filter.Filter(tradeData)
+
+			// the swaps of the bucket stored so far weigh in as a single trade
+			stored, ok, err := latestSeriesAt(ctx, tx, key, bucket.end)
+			if err != nil {
+				log.Printf("failed to load VWAP for pair %s: %v\n", bucket.pair, err)
+				return
+			}
+			if ok && stored.Status == StatusComputed && stored.CalculatedAt.Equal(bucket.end) {
+				tradeData = append(tradeData, TradeData{TokenName: key.tokenName, Pair: bucket.pair, Volume: stored.TotalVolume, Ratio: stored.VWAP})
+			}
+
+			row, err := c.price(ctx, tx, key, tradeData, VWAPAggregator{}, bucket.end)
+			if err != nil {
+				log.Printf("failed to calculate VWAP for pair %s: %v\n", bucket.pair, err)
+				return
+			}
+			mutex.Lock()
+			priced = append(priced, pricedBucket{pair: bucket.pair, row: row})
+			mutex.Unlock()
+		}(bucket, tradeData)
+	}
+
+	wg.Wait()
+
+	// oldest first, so that the latest price of each pair is saved last
+	sort.Slice(priced, func(i, j int) bool {
+		return priced[i].row.CalculatedAt.Before(priced[j].row.CalculatedAt)
+	})
+	run := make([]VWAPData, 0, len(priced))
+	for _, bucket := range priced {
+		run = append(run, bucket.row)
+	}
+	if err := c.commit(ctx, tx, newRunID(), run); err != nil {
+		return nil, err
+	}
+
+	vwapResults := make(map[Pair]Decimal)
+	for _, bucket := range priced {
+		vwapResults[bucket.pair] = bucket.row.VWAP
+	}
+	return vwapResults, nil
+}
+
+// swapToPairTrade turns a swap into a trade of its pair, priced in quote per base
+// and weighted by the base amount. Side tells whether the trader bought the base token.
+func swapToPairTrade(swap ParsedSwap) TradeData {
+	pair := NewPair(swap.TokenA.ID(), swap.TokenB.ID(), 0)
+
+	base, quote := swap.AmountA, swap.AmountB
+	if pair.Base != swap.TokenA.ID() {
+		base, quote = quote, base
+	}
+
+	side := SideBuy
+	if tokenIn, _ := swap.TokenIn(); tokenIn.ID() == pair.Base {
+		side = SideSell
+	}
+
+	return TradeData{
+		TokenName: pair.Base,
+		Pair:      pair,
+		Volume:    base,
+		Ratio:     quote.Quo(base),
+		Timestamp: int(swap.Time.Unix()),
+		Trader:    swap.Account,
+		Side:      side,
+		USD:       swap.TotalUsd,
+	}
+}
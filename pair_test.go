@@ -0,0 +1,102 @@
+package vwap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePair(t *testing.T) {
+	pair, err := ParsePair("gno.land/r/demo/bar:gno.land/r/demo/baz:100")
+	assert.NoError(t, err)
+	assert.Equal(t, Pair{Base: "gno.land/r/demo/bar", Quote: "gno.land/r/demo/baz", Fee: 100}, pair)
+	assert.Equal(t, "gno.land/r/demo/bar:gno.land/r/demo/baz:100", pair.String())
+
+	// pairs are canonical regardless of the token order
+	pair, err = ParsePair("gno.land/r/demo/baz:gno.land/r/demo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, NewPair("gno.land/r/demo/bar", "gno.land/r/demo/baz", 0), pair)
+
+	for _, invalid := range []string{"", "bar", "bar:", "bar:baz:fee", "bar:baz:-1", "a:b:1:2"} {
+		_, err := ParsePair(invalid)
+		assert.Error(t, err, "input %q", invalid)
+	}
+
+	assert.True(t, Pair{}.IsZero())
+	assert.Equal(t, "", Pair{}.String())
+}
+
+func TestPairVWAP(t *testing.T) {
+	db := newTestDB(t)
+
+	swaps := []Swap{
+		// 10 GNS for 20 USDC, then 30 USDC for 10 GNS
+		{Time: "2024-05-16 05:01:00", TokenA: SwapToken{Symbol: "USDC"}, TokenAAmount: "20", TokenB: SwapToken{Symbol: "GNS"}, TokenBAmount: "-10", TotalUsd: "20"},
+		{Time: "2024-05-16 05:02:00", TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "10", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "-30", TotalUsd: "30"},
+		{Time: "2024-05-16 05:03:00", TokenA: SwapToken{Symbol: "GNOT"}, TokenAAmount: "4", TokenB: SwapToken{Symbol: "GNS"}, TokenBAmount: "-1", TotalUsd: "2"},
+		{Time: "2024-05-16 05:04:00", TokenA: SwapToken{Symbol: "GNOT"}, TokenAAmount: "0", TokenB: SwapToken{Symbol: "GNS"}, TokenBAmount: "-1", TotalUsd: "0"},
+	}
+
+	parsed, errs := ParseSwaps(swaps)
+	assert.Len(t, errs, 1)
+
+	results, err := NewCalculator(db, nil, Config{}).PairVWAP(context.Background(), parsed)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	// GNS sorts before USDC, so the pair is quoted in USDC per GNS: (10*2 + 10*3) / 20
+	gnsUSDC := NewPair("GNS", "USDC", 0)
+	assert.Equal(t, "2.5", results[gnsUSDC].String())
+	// GNOT sorts before GNS: 1 GNS per 4 GNOT
+	assert.Equal(t, "0.25", results[NewPair("GNS", "GNOT", 0)].String())
+
+	row, err := latestSeries(context.Background(), db, seriesKey{tokenName: "GNS", pair: gnsUSDC.String(), aggregator: AggregatorVWAP})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.5", row.VWAP.String())
+	assert.Equal(t, "20", row.TotalVolume.String())
+	assert.Equal(t, StatusComputed, row.Status)
+
+	// pair rows do not leak into the token series
+	_, err = latestSeries(context.Background(), db, seriesKey{tokenName: "GNS", aggregator: AggregatorVWAP})
+	assert.Error(t, err)
+}
+
+func TestPairVWAPBucketsSwapsByTime(t *testing.T) {
+	db := newTestDB(t)
+	calculator := NewCalculator(db, nil, Config{})
+	gnsUSDC := NewPair("GNS", "USDC", 0)
+
+	pairSwap := func(at, gns, usdc string) Swap {
+		return Swap{Time: at, TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: gns, TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "-" + usdc, TotalUsd: usdc}
+	}
+
+	// a backlog spanning two buckets
+	parsed, errs := ParseSwaps([]Swap{
+		pairSwap("2024-05-16 05:01:00", "10", "20"),
+		pairSwap("2024-05-16 05:11:00", "10", "40"),
+	})
+	assert.Empty(t, errs)
+	results, err := calculator.PairVWAP(context.Background(), parsed)
+	assert.NoError(t, err)
+	// the price of the latest bucket
+	assert.Equal(t, "4", results[gnsUSDC].String())
+
+	// a later batch of the second bucket adds up with the stored swaps
+	parsed, errs = ParseSwaps([]Swap{pairSwap("2024-05-16 05:12:00", "30", "60")})
+	assert.Empty(t, errs)
+	results, err = calculator.PairVWAP(context.Background(), parsed)
+	assert.NoError(t, err)
+	// (10*4 + 30*2) / 40
+	assert.Equal(t, "2.5", results[gnsUSDC].String())
+
+	var rows []VWAPData
+	db.Where("pair = ?", gnsUSDC.String()).Order("calculated_at").Find(&rows)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 10, 0, 0, time.UTC), rows[0].CalculatedAt.UTC())
+	assert.Equal(t, "2", rows[0].VWAP.String())
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 20, 0, 0, time.UTC), rows[1].CalculatedAt.UTC())
+	assert.Equal(t, "2.5", rows[1].VWAP.String())
+	assert.Equal(t, "40", rows[1].TotalVolume.String())
+}
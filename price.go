@@ -3,14 +3,11 @@ package vwap
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
-	"net/http"
-	"strconv"
-	"time"
 )
 
-const priceEndpoint = "http://dev.api.gnoswap.io/v1/tokens/prices"
+// PriceEndpoint is the Gnoswap dev API endpoint serving token prices.
+const PriceEndpoint = "http://dev.api.gnoswap.io/v1/tokens/prices"
 
 type TokenPrice struct {
 	Path              string       `json:"path"`
@@ -45,60 +42,12 @@ type PricesResponse struct {
 	Data  []TokenPrice    `json:"data"`
 }
 
-func fetchTokenPrices(endpoint string) ([]TokenPrice, error) {
-	client := &http.Client{}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v\n", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Received non-OK response: %d\n", resp.StatusCode)
-		return nil, fmt.Errorf("received non-OK status code: %d", resp.StatusCode)
-	}
-
+func fetchTokenPrices(ctx context.Context, client *Client, endpoint string) ([]TokenPrice, error) {
 	var apiResponse PricesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		log.Printf("Error decoding response: %v\n", err)
+	if err := client.GetJSON(ctx, endpoint, &apiResponse); err != nil {
+		log.Printf("failed to fetch token prices: %v\n", err)
 		return nil, err
 	}
 
 	return apiResponse.Data, nil
 }
-
-func extractTrades(prices []TokenPrice, volumeByToken map[string]float64) map[string][]TradeData {
-	trades := make(map[string][]TradeData)
-	for _, price := range prices {
-		usd, err := strconv.ParseFloat(price.USD, 64)
-		if err != nil {
-			fmt.Printf("failed to parse USD price for token %s: %v\n", price.Path, err)
-			continue
-		}
-
-		volume, ok := volumeByToken[price.Path]
-		if !ok {
-			fmt.Printf("volume not found for token %s\n", price.Path)
-			continue
-		}
-
-		trades[price.Path] = append(trades[price.Path], TradeData{
-			TokenName: price.Path,
-			Volume:    volume,
-			Ratio:     usd,
-			Timestamp: int(time.Now().Unix()),
-		})
-	}
-
-	return trades
-}
@@ -1,6 +1,7 @@
 package vwap
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -56,7 +57,7 @@ func TestFetchTokenPrices(t *testing.T) {
 
 	apiEndpoint := server.URL
 
-	prices, err := fetchTokenPrices(apiEndpoint)
+	prices, err := fetchTokenPrices(context.Background(), DefaultClient, apiEndpoint)
 	assert.NoError(t, err, "Failed to fetch token prices")
 
 	expectedPrices := []TokenPrice{
@@ -97,7 +98,7 @@ func TestFetchTokenPrices(t *testing.T) {
 func TestFetchTokenPricesLive(t *testing.T) {
 	t.Parallel()
 
-	prices, err := fetchTokenPrices(priceEndpoint)
+	prices, err := fetchTokenPrices(context.Background(), DefaultClient, PriceEndpoint)
 	if err != nil {
 		t.Fatalf("Failed to fetch token prices: %v", err)
 	}
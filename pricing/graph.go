@@ -0,0 +1,201 @@
+package pricing
+
+import (
+	"sort"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+// Graph is an undirected token graph built from observed swaps.
+// Each edge accumulates the amounts traded on both sides of a token pair, in
+// human units, which gives the volume weighted exchange rate of the pair and
+// its traded volume.
+type Graph struct {
+	decimals Decimals
+	edges    map[string]map[string]*edge
+}
+
+type edge struct {
+	// volumes holds the total amount traded of each of the two tokens.
+	volumes map[string]vwap.Decimal
+}
+
+// rate returns the amount of quote exchanged per unit of base on this edge.
+func (e *edge) rate(base, quote string) (vwap.Decimal, bool) {
+	baseVolume := e.volumes[base]
+	if baseVolume.IsZero() || e.volumes[quote].IsZero() {
+		return vwap.Decimal{}, false
+	}
+	return e.volumes[quote].Quo(baseVolume), true
+}
+
+// Quote is a price derived by routing through the graph.
+type Quote struct {
+	Token string
+	// Price is expressed in WUGNOT.
+	Price vwap.Decimal
+	// Route lists the tokens from Token to WUGNOT, both included.
+	Route []string
+	// Liquidity is the smallest edge volume along the route, valued in WUGNOT.
+	Liquidity vwap.Decimal
+}
+
+// NewGraph builds a graph from the transactions, normalizing amounts with decimals.
+func NewGraph(transactions []Transaction, decimals Decimals) *Graph {
+	g := &Graph{decimals: decimals, edges: make(map[string]map[string]*edge)}
+	for _, tx := range transactions {
+		g.Add(tx)
+	}
+	return g
+}
+
+// Add records a swap in the graph. Swaps with a zero amount or unknown decimals
+// on either side are ignored.
+func (g *Graph) Add(tx Transaction) {
+	amount0, ok0 := tx.Amount(tx.Token0Path, g.decimals)
+	amount1, ok1 := tx.Amount(tx.Token1Path, g.decimals)
+	if tx.Token0Path == tx.Token1Path || !ok0 || !ok1 || amount0.IsZero() || amount1.IsZero() {
+		return
+	}
+
+	e := g.edge(tx.Token0Path, tx.Token1Path)
+	e.volumes[tx.Token0Path] = e.volumes[tx.Token0Path].Add(amount0)
+	e.volumes[tx.Token1Path] = e.volumes[tx.Token1Path].Add(amount1)
+}
+
+func (g *Graph) edge(a, b string) *edge {
+	if e, ok := g.edges[a][b]; ok {
+		return e
+	}
+
+	e := &edge{volumes: make(map[string]vwap.Decimal, 2)}
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if g.edges[pair[0]] == nil {
+			g.edges[pair[0]] = make(map[string]*edge)
+		}
+		g.edges[pair[0]][pair[1]] = e
+	}
+	return e
+}
+
+// Tokens returns every token seen in the graph, sorted.
+func (g *Graph) Tokens() []string {
+	tokens := make([]string, 0, len(g.edges))
+	for token := range g.edges {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// Price returns the quote of token. ok is false if token has no route to WUGNOT.
+func (g *Graph) Price(token string) (Quote, bool) {
+	quote, ok := g.Prices()[token]
+	return quote, ok
+}
+
+// Prices returns the quote of every token connected to WUGNOT.
+//
+// Routes are chosen to maximize the liquidity of their thinnest edge, where the
+// volume of an edge is valued in WUGNOT through the price of the token closer to
+// WUGNOT. Ties are broken by the number of hops, then by token path.
+func (g *Graph) Prices() map[string]Quote {
+	type candidate struct {
+		price     vwap.Decimal
+		liquidity vwap.Decimal
+		bounded   bool // false only for WUGNOT, whose liquidity is unlimited
+		route     []string
+	}
+
+	// wider reports whether a is a better route than b.
+	wider := func(a, b candidate) bool {
+		if a.bounded != b.bounded {
+			return !a.bounded
+		}
+		if c := a.liquidity.Cmp(b.liquidity); c != 0 {
+			return c > 0
+		}
+		return len(a.route) < len(b.route)
+	}
+
+	best := map[string]candidate{
+		WUGNOT: {price: vwap.NewDecimalFromInt(1), route: []string{WUGNOT}},
+	}
+	done := make(map[string]bool)
+	quotes := make(map[string]Quote)
+
+	for {
+		// pick the widest unvisited candidate
+		var (
+			current string
+			found   bool
+		)
+		for _, token := range sortedKeys(best) {
+			if done[token] {
+				continue
+			}
+			if !found || wider(best[token], best[current]) {
+				current, found = token, true
+			}
+		}
+		if !found {
+			break
+		}
+
+		done[current] = true
+		from := best[current]
+		if current != WUGNOT {
+			quotes[current] = Quote{
+				Token:     current,
+				Price:     from.price,
+				Route:     reversed(from.route),
+				Liquidity: from.liquidity,
+			}
+		}
+
+		for neighbor, e := range g.edges[current] {
+			if done[neighbor] {
+				continue
+			}
+
+			rate, ok := e.rate(neighbor, current)
+			if !ok {
+				continue
+			}
+
+			liquidity := e.volumes[current].Mul(from.price)
+			if from.bounded && from.liquidity.Cmp(liquidity) < 0 {
+				liquidity = from.liquidity
+			}
+
+			next := candidate{
+				price:     rate.Mul(from.price),
+				liquidity: liquidity,
+				bounded:   true,
+				route:     append(append([]string(nil), from.route...), neighbor),
+			}
+			if existing, ok := best[neighbor]; !ok || wider(next, existing) {
+				best[neighbor] = next
+			}
+		}
+	}
+
+	return quotes
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func reversed(route []string) []string {
+	out := make([]string, len(route))
+	for i, token := range route {
+		out[len(route)-1-i] = token
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+const (
+	foo = "gno.land/r/demo/foo"
+	qux = "gno.land/r/demo/qux"
+)
+
+func TestGraphMultiHop(t *testing.T) {
+	graph := NewGraph([]Transaction{
+		tx(WUGNOT, GNS, 200, -100, t0), // GNS = 2 WUGNOT
+		tx(baz, GNS, 30, -10, t0),      // BAZ = 1/3 GNS
+		tx(qux, baz, 5, -15, t0),       // QUX = 3 BAZ
+	}, nil)
+
+	quote, ok := graph.Price(qux)
+	assert.True(t, ok)
+	assert.Equal(t, "2", quote.Price.String())
+	assert.Equal(t, []string{qux, baz, GNS, WUGNOT}, quote.Route)
+
+	quote, ok = graph.Price(baz)
+	assert.True(t, ok)
+	assert.Equal(t, []string{baz, GNS, WUGNOT}, quote.Route)
+
+	// 10 GNS at 2 WUGNOT is the thinnest edge on the route
+	assert.Equal(t, "20", quote.Liquidity.String())
+}
+
+func TestGraphPrefersLiquidRoute(t *testing.T) {
+	graph := NewGraph([]Transaction{
+		tx(WUGNOT, GNS, 2000, -1000, t0),
+		tx(bar, GNS, 500, -1000, t0), // BAR = 2 GNS = 4 WUGNOT, 2000 WUGNOT traded
+		tx(bar, WUGNOT, 1, -5, t0),   // thin direct pool, BAR = 5 WUGNOT
+	}, nil)
+
+	quote, ok := graph.Price(bar)
+	assert.True(t, ok)
+	assert.Equal(t, []string{bar, GNS, WUGNOT}, quote.Route)
+	assert.Equal(t, "4", quote.Price.String())
+	assert.Equal(t, "2000", quote.Liquidity.String())
+}
+
+func TestGraphUnreachableToken(t *testing.T) {
+	graph := NewGraph([]Transaction{
+		tx(WUGNOT, GNS, 2, -1, t0),
+		tx(foo, bar, 1, -1, t0),
+		tx(baz, GNS, 0, -1, t0), // empty swaps do not create edges
+	}, nil)
+
+	_, ok := graph.Price(foo)
+	assert.False(t, ok)
+	_, ok = graph.Price(baz)
+	assert.False(t, ok)
+
+	prices := graph.Prices()
+	assert.Len(t, prices, 1)
+	assert.Contains(t, prices, GNS)
+	assert.Equal(t, []string{bar, foo, GNS, WUGNOT}, graph.Tokens())
+}
+
+func TestGraphNormalizesDecimals(t *testing.T) {
+	graph := NewGraph([]Transaction{
+		tx(WUGNOT, GNS, 2000000, -1000000000000000000, t0),
+		tx(bar, GNS, 3, -1000000000000000000, t0),
+	}, DecimalsMap{WUGNOT: 6, GNS: 18, bar: 0})
+
+	quote, ok := graph.Price(bar)
+	assert.True(t, ok)
+	assert.Equal(t, vwap.NewDecimalFromInt(2).Quo(vwap.NewDecimalFromInt(3)).String(), quote.Price.String())
+	assert.Equal(t, "2", quote.Liquidity.String())
+}
@@ -0,0 +1,128 @@
+package pricing
+
+import (
+	"time"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+// DefaultInterval is the bucket size used by the Gnoswap charts.
+const DefaultInterval = 10 * time.Minute
+
+// PriceEntry holds the price of every known token at the end of a bucket.
+type PriceEntry struct {
+	Time   time.Time
+	Prices map[string]vwap.Decimal
+}
+
+// VolumeEntry holds the volume traded by each token within a bucket.
+type VolumeEntry struct {
+	Time    time.Time
+	Volumes map[string]vwap.Decimal
+}
+
+// RoundTime rounds t down to a multiple of interval.
+func RoundTime(t time.Time, interval time.Duration) time.Time {
+	return t.Truncate(interval)
+}
+
+// buckets returns the start of every bucket between the first and the last transaction.
+// transactions must be sorted by time.
+func buckets(transactions []Transaction, interval time.Duration) []time.Time {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	var starts []time.Time
+	last := transactions[len(transactions)-1].Time
+	for current := RoundTime(transactions[0].Time, interval); !current.After(last); current = current.Add(interval) {
+		starts = append(starts, current)
+	}
+	return starts
+}
+
+// PriceHistory replays transactions in time order and records the prices at the
+// end of every bucket. Each entry is stamped with the start of its bucket.
+func PriceHistory(transactions []Transaction, initialPrices map[string]vwap.Decimal, decimals Decimals, interval time.Duration) []PriceEntry {
+	sorted := sortedByTime(transactions)
+	currentPrices := copyPrices(initialPrices)
+
+	var priceHistory []PriceEntry
+	next := 0
+	for _, start := range buckets(sorted, interval) {
+		end := start.Add(interval)
+		for ; next < len(sorted) && sorted[next].Time.Before(end); next++ {
+			UpdatePrices(sorted[next], currentPrices, decimals)
+		}
+		priceHistory = append(priceHistory, PriceEntry{Time: start, Prices: copyPrices(currentPrices)})
+	}
+
+	return priceHistory
+}
+
+// VolumeHistory sums the amount traded by each token within every bucket, in human units.
+// Tokens with unknown decimals are left out.
+func VolumeHistory(transactions []Transaction, decimals Decimals, interval time.Duration) []VolumeEntry {
+	sorted := sortedByTime(transactions)
+
+	var volumeHistory []VolumeEntry
+	next := 0
+	for _, start := range buckets(sorted, interval) {
+		end := start.Add(interval)
+		volumes := make(map[string]vwap.Decimal)
+		for ; next < len(sorted) && sorted[next].Time.Before(end); next++ {
+			tx := sorted[next]
+			for _, token := range []string{tx.Token0Path, tx.Token1Path} {
+				if amount, ok := tx.Amount(token, decimals); ok {
+					volumes[token] = volumes[token].Add(amount)
+				}
+			}
+		}
+		volumeHistory = append(volumeHistory, VolumeEntry{Time: start, Volumes: volumes})
+	}
+
+	return volumeHistory
+}
+
+// VWAP returns the volume weighted average price of token over the transactions
+// executed within [from, to). Each trade is valued at the cross-rate price the token
+// had right after that trade, and weighted by the amount of token traded.
+// ok is false if token was not priced by any trade in the range.
+func VWAP(token string, transactions []Transaction, initialPrices map[string]vwap.Decimal, decimals Decimals, from, to time.Time) (price vwap.Decimal, ok bool) {
+	if token == WUGNOT {
+		return vwap.NewDecimalFromInt(1), true
+	}
+
+	prices := copyPrices(initialPrices)
+	var numerator, denominator vwap.Decimal
+
+	for _, tx := range sortedByTime(transactions) {
+		if !tx.Time.Before(to) {
+			break
+		}
+
+		UpdatePrices(tx, prices, decimals)
+		if tx.Time.Before(from) || !tx.involves(token) {
+			continue
+		}
+
+		tokenPrice, priced := prices[token]
+		if !priced || tokenPrice.IsZero() {
+			continue
+		}
+
+		volume, known := tx.Amount(token, decimals)
+		if !known {
+			continue
+		}
+
+		numerator = numerator.Add(tokenPrice.Mul(volume))
+		denominator = denominator.Add(volume)
+	}
+
+	if denominator.IsZero() {
+		return vwap.Decimal{}, false
+	}
+
+	return numerator.Quo(denominator), true
+}
@@ -0,0 +1,97 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+func TestRoundTime(t *testing.T) {
+	at := time.Date(2024, 5, 16, 5, 21, 17, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 20, 0, 0, time.UTC), RoundTime(at, DefaultInterval))
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC), RoundTime(at, time.Hour))
+}
+
+func TestPriceHistory(t *testing.T) {
+	transactions := []Transaction{
+		tx(bar, GNS, 10, -40, t0.Add(25*time.Minute)),
+		tx(GNS, WUGNOT, 1, -2, t0.Add(1*time.Minute)),
+		tx(GNS, WUGNOT, 1, -4, t0.Add(21*time.Minute)),
+	}
+
+	history := PriceHistory(transactions, map[string]vwap.Decimal{WUGNOT: vwap.NewDecimalFromInt(1)}, nil, DefaultInterval)
+	assert.Len(t, history, 3)
+
+	assert.Equal(t, t0, history[0].Time)
+	assert.Equal(t, "2", history[0].Prices[GNS].String())
+	assert.NotContains(t, history[0].Prices, bar)
+
+	// quiet bucket keeps the previous prices
+	assert.Equal(t, t0.Add(10*time.Minute), history[1].Time)
+	assert.Equal(t, "2", history[1].Prices[GNS].String())
+
+	assert.Equal(t, "4", history[2].Prices[GNS].String())
+	assert.Equal(t, "16", history[2].Prices[bar].String())
+}
+
+func TestPriceHistoryEmpty(t *testing.T) {
+	assert.Empty(t, PriceHistory(nil, nil, nil, DefaultInterval))
+	assert.Empty(t, VolumeHistory(nil, nil, DefaultInterval))
+}
+
+func TestVolumeHistory(t *testing.T) {
+	transactions := []Transaction{
+		tx(WUGNOT, GNS, 2, -1, t0.Add(1*time.Minute)),
+		tx(GNS, WUGNOT, 3, -12, t0.Add(2*time.Minute)),
+		tx(GNS, WUGNOT, 1, -4, t0.Add(21*time.Minute)),
+	}
+
+	history := VolumeHistory(transactions, nil, DefaultInterval)
+	assert.Len(t, history, 3)
+
+	assert.Equal(t, "4", history[0].Volumes[GNS].String())
+	assert.Equal(t, "14", history[0].Volumes[WUGNOT].String())
+	assert.Empty(t, history[1].Volumes)
+	assert.Equal(t, "1", history[2].Volumes[GNS].String())
+	assert.Equal(t, "4", history[2].Volumes[WUGNOT].String())
+}
+
+func TestVWAP(t *testing.T) {
+	transactions := []Transaction{
+		tx(GNS, WUGNOT, 1, -2, t0.Add(1*time.Minute)),
+		tx(GNS, WUGNOT, 3, -12, t0.Add(2*time.Minute)),
+		tx(GNS, WUGNOT, 1, -10, t0.Add(21*time.Minute)),
+	}
+
+	// (2*1 + 4*3) / 4
+	price, ok := VWAP(GNS, transactions, nil, nil, t0, t0.Add(DefaultInterval))
+	assert.True(t, ok)
+	assert.Equal(t, "3.5", price.String())
+
+	price, ok = VWAP(GNS, transactions, nil, nil, t0.Add(20*time.Minute), t0.Add(30*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, "10", price.String())
+
+	_, ok = VWAP(GNS, transactions, nil, nil, t0.Add(10*time.Minute), t0.Add(20*time.Minute))
+	assert.False(t, ok)
+
+	price, ok = VWAP(WUGNOT, nil, nil, nil, t0, t0)
+	assert.True(t, ok)
+	assert.Equal(t, "1", price.String())
+}
+
+func TestVolumeHistoryNormalizesDecimals(t *testing.T) {
+	transactions := []Transaction{
+		tx(WUGNOT, GNS, 2000000, -1000000000000000000, t0.Add(1*time.Minute)),
+		tx(bar, WUGNOT, 5, -1000000, t0.Add(2*time.Minute)),
+	}
+
+	history := VolumeHistory(transactions, DecimalsMap{WUGNOT: 6, GNS: 18}, DefaultInterval)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "3", history[0].Volumes[WUGNOT].String())
+	assert.Equal(t, "1", history[0].Volumes[GNS].String())
+	assert.NotContains(t, history[0].Volumes, bar)
+}
@@ -0,0 +1,150 @@
+// Package pricing derives token prices from raw on-chain swaps, without relying on
+// the Gnoswap price API. Prices are relative to the base token WUGNOT (see
+// UpdatePrices for how each swap is quoted) and obtained through cross rates:
+// directly from WUGNOT pools, or through GNS once GNS itself has a price.
+package pricing
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+const (
+	// WUGNOT is the base token. Its price is always 1.
+	WUGNOT = string(vwap.WUGNOT)
+	// GNS is used as an intermediate token for pairs without a WUGNOT side.
+	GNS = string(vwap.GNS)
+)
+
+// Decimals provides the number of decimals of each token, which turns raw
+// on-chain amounts into human units. *vwap.Registry implements it.
+type Decimals interface {
+	Decimals(path string) (int, bool)
+}
+
+// DecimalsMap is a Decimals backed by a map of token path to decimals.
+type DecimalsMap map[string]int
+
+func (m DecimalsMap) Decimals(path string) (int, bool) {
+	decimals, ok := m[path]
+	return decimals, ok
+}
+
+// Transaction is a single swap executed against a pool.
+//
+// Amount0 is the raw amount of Token0 paid into the pool and is positive.
+// Amount1 is the raw amount of Token1 taken out of the pool and is negative.
+// Raw amounts are in the smallest unit of each token and are normalized with
+// the token decimals before any ratio or volume is computed.
+type Transaction struct {
+	ID         string
+	Token0Path string
+	Token1Path string
+	Amount0    *big.Int
+	Amount1    *big.Int
+	Time       time.Time
+}
+
+// Amount returns the absolute amount of token traded in tx, in human units.
+// If decimals is nil, the raw amount is returned.
+// ok is false if token is not traded in tx or its decimals are unknown.
+func (tx Transaction) Amount(token string, decimals Decimals) (vwap.Decimal, bool) {
+	var raw *big.Int
+	switch token {
+	case tx.Token0Path:
+		raw = tx.Amount0
+	case tx.Token1Path:
+		raw = tx.Amount1
+	default:
+		return vwap.Decimal{}, false
+	}
+
+	amount := vwap.NewDecimalFromBigInt(raw).Abs()
+	if decimals == nil {
+		return amount, true
+	}
+
+	places, ok := decimals.Decimals(token)
+	if !ok {
+		return vwap.Decimal{}, false
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	return amount.Quo(vwap.NewDecimalFromBigInt(unit)), true
+}
+
+// rate returns the amount of quote received or paid per unit of base in tx.
+// ok is false if either amount is zero or has unknown decimals.
+func (tx Transaction) rate(base, quote string, decimals Decimals) (vwap.Decimal, bool) {
+	baseAmount, okBase := tx.Amount(base, decimals)
+	quoteAmount, okQuote := tx.Amount(quote, decimals)
+	if !okBase || !okQuote || baseAmount.IsZero() || quoteAmount.IsZero() {
+		return vwap.Decimal{}, false
+	}
+	return quoteAmount.Quo(baseAmount), true
+}
+
+// other returns the counter token of token in tx.
+func (tx Transaction) other(token string) string {
+	if tx.Token0Path == token {
+		return tx.Token1Path
+	}
+	return tx.Token0Path
+}
+
+// involves reports whether token is one of the two sides of tx.
+func (tx Transaction) involves(token string) bool {
+	return tx.Token0Path == token || tx.Token1Path == token
+}
+
+// UpdatePrices applies the exchange rate observed in tx to prices.
+//
+// The rate is the amount of Token1 per unit of Token0. A token traded against
+// WUGNOT is priced at that rate, so a token bought with WUGNOT (WUGNOT being
+// Token0) is priced in units of itself per WUGNOT. A token traded against GNS is
+// priced at the rate times the current GNS price, if GNS already has one and the
+// token has no price yet. The WUGNOT price is never changed.
+func UpdatePrices(tx Transaction, prices map[string]vwap.Decimal, decimals Decimals) {
+	prices[WUGNOT] = vwap.NewDecimalFromInt(1)
+
+	rate, ok := tx.rate(tx.Token0Path, tx.Token1Path, decimals)
+	if !ok {
+		return
+	}
+
+	if tx.involves(WUGNOT) {
+		if token := tx.other(WUGNOT); token != WUGNOT {
+			prices[token] = rate
+		}
+		return
+	}
+
+	gnsPrice := prices[GNS]
+	if !tx.involves(GNS) || gnsPrice.IsZero() {
+		return
+	}
+
+	token := tx.other(GNS)
+	if _, ok := prices[token]; !ok {
+		prices[token] = rate.Mul(gnsPrice)
+	}
+}
+
+// sortedByTime returns a copy of transactions ordered from oldest to newest.
+func sortedByTime(transactions []Transaction) []Transaction {
+	sorted := append([]Transaction(nil), transactions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+	return sorted
+}
+
+func copyPrices(original map[string]vwap.Decimal) map[string]vwap.Decimal {
+	newMap := make(map[string]vwap.Decimal, len(original))
+	for key, value := range original {
+		newMap[key] = value
+	}
+	return newMap
+}
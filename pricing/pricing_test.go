@@ -0,0 +1,121 @@
+package pricing
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gnoswap-labs/vwap"
+)
+
+const (
+	bar = "gno.land/r/demo/bar"
+	baz = "gno.land/r/demo/baz"
+)
+
+var t0 = time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+func tx(token0, token1 string, amount0, amount1 int64, at time.Time) Transaction {
+	return Transaction{
+		Token0Path: token0,
+		Token1Path: token1,
+		Amount0:    big.NewInt(amount0),
+		Amount1:    big.NewInt(amount1),
+		Time:       at,
+	}
+}
+
+func TestUpdatePricesDirectWUGNOT(t *testing.T) {
+	prices := map[string]vwap.Decimal{}
+
+	// sell 100 gns for 250 wugnot
+	UpdatePrices(tx(GNS, WUGNOT, 100, -250, t0), prices, nil)
+	assert.Equal(t, "2.5", prices[GNS].String())
+	assert.Equal(t, "1", prices[WUGNOT].String())
+
+	// pay 2,000,000 wugnot for 1,000,000 gns: priced in gns per wugnot
+	UpdatePrices(tx(WUGNOT, GNS, 2000000, -1000000, t0), prices, nil)
+	assert.Equal(t, "0.5", prices[GNS].String())
+	assert.Equal(t, "1", prices[WUGNOT].String())
+}
+
+func TestUpdatePricesThroughGNS(t *testing.T) {
+	prices := map[string]vwap.Decimal{}
+
+	// GNS is not priced yet, so bar cannot be priced
+	UpdatePrices(tx(bar, GNS, 10, -40, t0), prices, nil)
+	assert.NotContains(t, prices, bar)
+
+	UpdatePrices(tx(GNS, WUGNOT, 1, -3, t0), prices, nil)
+	UpdatePrices(tx(bar, GNS, 10, -40, t0), prices, nil)
+	assert.Equal(t, "12", prices[bar].String())
+
+	// a token priced once keeps its price through GNS
+	UpdatePrices(tx(bar, GNS, 10, -80, t0), prices, nil)
+	assert.Equal(t, "12", prices[bar].String())
+
+	// same pool in the other direction
+	UpdatePrices(tx(GNS, baz, 1, -6, t0), prices, nil)
+	assert.Equal(t, "18", prices[baz].String())
+}
+
+func TestUpdatePricesIgnoresEmptyAmounts(t *testing.T) {
+	prices := map[string]vwap.Decimal{}
+
+	UpdatePrices(tx(WUGNOT, GNS, 0, -10, t0), prices, nil)
+	assert.NotContains(t, prices, GNS)
+}
+
+func TestTransactionAmount(t *testing.T) {
+	decimals := DecimalsMap{WUGNOT: 6, GNS: 18}
+	trade := tx(WUGNOT, GNS, 2500000, -3000000000000000000, t0)
+
+	amount, ok := trade.Amount(WUGNOT, decimals)
+	assert.True(t, ok)
+	assert.Equal(t, "2.5", amount.String())
+
+	amount, ok = trade.Amount(GNS, decimals)
+	assert.True(t, ok)
+	assert.Equal(t, "3", amount.String())
+
+	amount, ok = trade.Amount(GNS, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "3000000000000000000", amount.String())
+
+	_, ok = trade.Amount(bar, decimals)
+	assert.False(t, ok)
+
+	_, ok = tx(WUGNOT, bar, 1, -1, t0).Amount(bar, decimals)
+	assert.False(t, ok)
+}
+
+func TestUpdatePricesNormalizesDecimals(t *testing.T) {
+	decimals := DecimalsMap{WUGNOT: 6, GNS: 18, bar: 0}
+	prices := map[string]vwap.Decimal{}
+
+	// 1 GNS for 2 WUGNOT: the raw ratio would be off by 10^12
+	UpdatePrices(tx(GNS, WUGNOT, 1000000000000000000, -2000000, t0), prices, decimals)
+	assert.Equal(t, "2", prices[GNS].String())
+
+	// 4 BAR for 1 GNS
+	UpdatePrices(tx(bar, GNS, 4, -1000000000000000000, t0), prices, decimals)
+	assert.Equal(t, "0.5", prices[bar].String())
+
+	// unknown decimals leave the price untouched
+	UpdatePrices(tx(baz, WUGNOT, 1, -1000000, t0), prices, decimals)
+	assert.NotContains(t, prices, baz)
+}
+
+func TestRegistryProvidesDecimals(t *testing.T) {
+	registry, err := vwap.NewRegistry([]vwap.Token{
+		{Path: WUGNOT, Decimals: 6, Base: true},
+		{Path: GNS, Decimals: 18},
+	})
+	assert.NoError(t, err)
+
+	prices := map[string]vwap.Decimal{}
+	UpdatePrices(tx(GNS, WUGNOT, 1000000000000000000, -3000000, t0), prices, registry)
+	assert.Equal(t, "3", prices[GNS].String())
+}
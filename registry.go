@@ -0,0 +1,262 @@
+package vwap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Token describes a token known to a Registry.
+type Token struct {
+	Path     string `json:"path" yaml:"path"`
+	Symbol   string `json:"symbol" yaml:"symbol"`
+	Decimals int    `json:"decimals" yaml:"decimals"`
+	// Base marks the base token, returned by Registry.Base. At most one token may be
+	// the base. It does not change how prices are quoted: VWAP is quoted in USD and
+	// package pricing always quotes against WUGNOT.
+	Base bool `json:"base" yaml:"base"`
+	// Enabled tokens are kept by FilterSwaps and priced by VWAP, which stores a
+	// no_data row for those without a price yet.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// RegistryFile is the layout of a registry config file, in YAML or JSON:
+//
+//	tokens:
+//	  - path: gno.land/r/demo/wugnot
+//	    symbol: WUGNOT
+//	    decimals: 6
+//	    base: true
+//	    enabled: true
+type RegistryFile struct {
+	Tokens []Token `json:"tokens" yaml:"tokens"`
+}
+
+// Registry holds the set of tokens the service works with.
+// It is safe for concurrent use and can be reloaded from its file at runtime.
+type Registry struct {
+	path string
+
+	mu       sync.RWMutex
+	tokens   []Token
+	byPath   map[string]Token
+	bySymbol map[string]Token
+}
+
+// defaultTokens keeps the behavior of the service when no registry file is given.
+var defaultTokens = []Token{
+	{Path: "gnot", Symbol: "GNOT", Decimals: 6, Enabled: true},
+	{Path: string(WUGNOT), Symbol: "WUGNOT", Decimals: 6, Base: true},
+	{Path: string(GNS), Symbol: "GNS", Decimals: 6, Enabled: true},
+	{Path: "gno.land/r/demo/usdc", Symbol: "USDC", Decimals: 6, Enabled: true},
+	{Path: string(FOO), Symbol: "FOO", Decimals: 6},
+	{Path: string(BAR), Symbol: "BAR", Decimals: 6},
+	{Path: string(BAZ), Symbol: "BAZ", Decimals: 6},
+	{Path: string(QUX), Symbol: "QUX", Decimals: 6},
+}
+
+var defaultRegistry = mustNewRegistry(defaultTokens)
+
+// DefaultRegistry returns the registry used when none is configured.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry returns a registry holding the given tokens.
+func NewRegistry(tokens []Token) (*Registry, error) {
+	r := &Registry{}
+	if err := r.set(tokens); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func mustNewRegistry(tokens []Token) *Registry {
+	r, err := NewRegistry(tokens)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LoadRegistry reads a registry from a YAML (.yaml, .yml) or JSON file.
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the registry file again. The registry is left unchanged on error.
+func (r *Registry) Reload() error {
+	if r.path == "" {
+		return fmt.Errorf("registry has no file to reload from")
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	var file RegistryFile
+	switch strings.ToLower(filepath.Ext(r.path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", r.path, err)
+	}
+
+	return r.set(file.Tokens)
+}
+
+// WatchSIGHUP reloads the registry every time the process receives SIGHUP,
+// until ctx is done. Failed reloads are logged and keep the previous tokens.
+func (r *Registry) WatchSIGHUP(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if err := r.Reload(); err != nil {
+					log.Printf("failed to reload token registry: %v\n", err)
+					continue
+				}
+				log.Printf("reloaded token registry from %s\n", r.path)
+			}
+		}
+	}()
+}
+
+func (r *Registry) set(tokens []Token) error {
+	byPath := make(map[string]Token, len(tokens))
+	bySymbol := make(map[string]Token, len(tokens))
+	hasBase := false
+
+	for _, token := range tokens {
+		if token.Path == "" {
+			return fmt.Errorf("token %q has no path", token.Symbol)
+		}
+		if _, ok := byPath[token.Path]; ok {
+			return fmt.Errorf("duplicate token path %s", token.Path)
+		}
+		if token.Decimals < 0 {
+			return fmt.Errorf("token %s has negative decimals", token.Path)
+		}
+		if token.Base {
+			if hasBase {
+				return fmt.Errorf("more than one base token")
+			}
+			hasBase = true
+		}
+
+		byPath[token.Path] = token
+		if token.Symbol != "" {
+			bySymbol[token.Symbol] = token
+		}
+	}
+
+	sorted := append([]Token(nil), tokens...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = sorted
+	r.byPath = byPath
+	r.bySymbol = bySymbol
+
+	return nil
+}
+
+// Tokens returns every token of the registry, ordered by path.
+func (r *Registry) Tokens() []Token {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Token(nil), r.tokens...)
+}
+
+// Token returns the token with the given path.
+func (r *Registry) Token(path string) (Token, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	token, ok := r.byPath[path]
+	return token, ok
+}
+
+// TokenBySymbol returns the token with the given symbol.
+func (r *Registry) TokenBySymbol(symbol string) (Token, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	token, ok := r.bySymbol[symbol]
+	return token, ok
+}
+
+// Decimals returns the number of decimals of the token with the given path.
+func (r *Registry) Decimals(path string) (int, bool) {
+	token, ok := r.Token(path)
+	return token.Decimals, ok
+}
+
+// Base returns the base token, if the registry has one.
+func (r *Registry) Base() (Token, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, token := range r.tokens {
+		if token.Base {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// Enabled reports whether the token with the given path is enabled.
+func (r *Registry) Enabled(path string) bool {
+	token, ok := r.Token(path)
+	return ok && token.Enabled
+}
+
+// EnabledSymbol reports whether the token with the given symbol is enabled.
+func (r *Registry) EnabledSymbol(symbol string) bool {
+	token, ok := r.TokenBySymbol(symbol)
+	return ok && token.Enabled
+}
+
+// enabledSwapToken reports whether the token of a swap is enabled, looking it up
+// by path when the swap carries one and by symbol otherwise.
+func (r *Registry) enabledSwapToken(token SwapToken) bool {
+	if token.Path != "" {
+		return r.Enabled(token.Path)
+	}
+	return r.EnabledSymbol(token.Symbol)
+}
+
+// FilterSwaps keeps the swaps with at least one enabled token.
+func (r *Registry) FilterSwaps(swaps []Swap) []Swap {
+	filteredSwaps := make([]Swap, 0)
+	for _, swap := range swaps {
+		if r.enabledSwapToken(swap.TokenA) || r.enabledSwapToken(swap.TokenB) {
+			filteredSwaps = append(filteredSwaps, swap)
+		}
+	}
+	return filteredSwaps
+}
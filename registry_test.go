@@ -0,0 +1,150 @@
+package vwap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const registryYAML = `
+tokens:
+  - path: gno.land/r/demo/wugnot
+    symbol: WUGNOT
+    decimals: 6
+    base: true
+    enabled: true
+  - path: gno.land/r/demo/foo
+    symbol: FOO
+    decimals: 18
+    enabled: true
+  - path: gno.land/r/demo/bar
+    symbol: BAR
+    decimals: 6
+    enabled: false
+`
+
+func TestLoadRegistryYAML(t *testing.T) {
+	registry, err := LoadRegistry(writeFile(t, "tokens.yaml", registryYAML))
+	assert.NoError(t, err)
+
+	tokens := registry.Tokens()
+	assert.Len(t, tokens, 3)
+	assert.Equal(t, "gno.land/r/demo/bar", tokens[0].Path)
+
+	foo, ok := registry.Token("gno.land/r/demo/foo")
+	assert.True(t, ok)
+	assert.Equal(t, Token{Path: "gno.land/r/demo/foo", Symbol: "FOO", Decimals: 18, Enabled: true}, foo)
+
+	base, ok := registry.Base()
+	assert.True(t, ok)
+	assert.Equal(t, "WUGNOT", base.Symbol)
+
+	assert.True(t, registry.Enabled("gno.land/r/demo/foo"))
+	assert.False(t, registry.Enabled("gno.land/r/demo/bar"))
+	assert.False(t, registry.Enabled("gno.land/r/demo/qux"))
+	assert.True(t, registry.EnabledSymbol("FOO"))
+	assert.False(t, registry.EnabledSymbol("BAR"))
+}
+
+func TestLoadRegistryJSON(t *testing.T) {
+	registry, err := LoadRegistry(writeFile(t, "tokens.json", `{"tokens":[{"path":"gno.land/r/demo/gns","symbol":"GNS","decimals":6,"enabled":true}]}`))
+	assert.NoError(t, err)
+
+	token, ok := registry.TokenBySymbol("GNS")
+	assert.True(t, ok)
+	assert.Equal(t, "gno.land/r/demo/gns", token.Path)
+
+	_, ok = registry.Base()
+	assert.False(t, ok)
+}
+
+func TestRegistryValidation(t *testing.T) {
+	invalid := [][]Token{
+		{{Symbol: "FOO"}},
+		{{Path: "foo"}, {Path: "foo"}},
+		{{Path: "foo", Base: true}, {Path: "bar", Base: true}},
+		{{Path: "foo", Decimals: -1}},
+	}
+	for _, tokens := range invalid {
+		_, err := NewRegistry(tokens)
+		assert.Error(t, err, "tokens %v", tokens)
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	path := writeFile(t, "tokens.json", `{"tokens":[{"path":"foo","symbol":"FOO","enabled":true}]}`)
+	registry, err := LoadRegistry(path)
+	assert.NoError(t, err)
+
+	writeTo(t, path, `{"tokens":[{"path":"foo","symbol":"FOO","enabled":false},{"path":"bar","symbol":"BAR","enabled":true}]}`)
+	assert.NoError(t, registry.Reload())
+	assert.False(t, registry.Enabled("foo"))
+	assert.True(t, registry.Enabled("bar"))
+
+	// a broken file keeps the previous tokens
+	writeTo(t, path, `{"tokens":[{"symbol":"BAZ"}]}`)
+	assert.Error(t, registry.Reload())
+	assert.True(t, registry.Enabled("bar"))
+
+	_, err = NewRegistry(nil)
+	assert.NoError(t, err)
+}
+
+func TestFilterSwaps(t *testing.T) {
+	swaps := []Swap{
+		{TokenA: SwapToken{Symbol: "GNS"}, TokenB: SwapToken{Symbol: "FOO"}},
+		{TokenA: SwapToken{Symbol: "FOO"}, TokenB: SwapToken{Symbol: "BAR"}},
+		{TokenA: SwapToken{Symbol: "BAR"}, TokenB: SwapToken{Symbol: "USDC"}},
+	}
+
+	filtered := FilterSwaps(swaps)
+	assert.Equal(t, []Swap{swaps[0], swaps[2]}, filtered)
+
+	registry, err := NewRegistry([]Token{{Path: "gno.land/r/demo/foo", Symbol: "FOO", Enabled: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Swap{swaps[0], swaps[1]}, registry.FilterSwaps(swaps))
+}
+
+func TestVWAPWithRegistry(t *testing.T) {
+	db := newTestDB(t)
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now()),
+		usdcSwap("0x2", "gno.land/r/demo/bar", "2", "61", time.Now()),
+	})
+	registry, err := NewRegistry([]Token{{Path: "gno.land/r/demo/bar", Enabled: true}})
+	assert.NoError(t, err)
+
+	result, err := NewCalculator(db, source, Config{Registry: registry}).VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result.Prices(), 1)
+	assert.Contains(t, result.Prices(), "gno.land/r/demo/bar")
+}
+
+func TestVWAPPricesEveryEnabledToken(t *testing.T) {
+	db := newTestDB(t)
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now()),
+	})
+	registry, err := NewRegistry([]Token{
+		{Path: "gno.land/r/demo/foo", Enabled: true},
+		{Path: "gno.land/r/demo/bar", Enabled: true},
+		{Path: "gno.land/r/demo/baz"},
+	})
+	assert.NoError(t, err)
+
+	result, err := NewCalculator(db, source, Config{Registry: registry}).VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result.Tokens, 2)
+	assert.Equal(t, TokenSuccess, result.Tokens["gno.land/r/demo/foo"].Status)
+	// bar was never traded: it is reported and stored without a price
+	assert.Equal(t, TokenSkipped, result.Tokens["gno.land/r/demo/bar"].Status)
+	assert.ErrorIs(t, result.Tokens["gno.land/r/demo/bar"].Err, ErrMissingVolume)
+
+	var row VWAPData
+	assert.NoError(t, db.Where("token_name = ?", "gno.land/r/demo/bar").First(&row).Error)
+	assert.Equal(t, StatusNoData, row.Status)
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package vwap
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryReloadsOnSIGHUP(t *testing.T) {
+	path := writeFile(t, "tokens.json", `{"tokens":[{"path":"foo","enabled":true}]}`)
+	registry, err := LoadRegistry(path)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry.WatchSIGHUP(ctx)
+
+	writeTo(t, path, `{"tokens":[{"path":"foo","enabled":false}]}`)
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool {
+		return !registry.Enabled("foo")
+	}, time.Second, 10*time.Millisecond)
+}
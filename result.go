@@ -0,0 +1,134 @@
+package vwap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Errors wrapped by the per-token errors of a Result.
+var (
+	// ErrMissingVolume is reported for tokens without traded volume within the window,
+	// whose last known price is carried forward.
+	ErrMissingVolume = errors.New("missing volume")
+	// ErrParse is reported for the tokens of swaps that cannot be parsed.
+	ErrParse = errors.New("parse error")
+	// ErrStorage is reported for tokens whose price could not be read or stored.
+	ErrStorage = errors.New("storage error")
+	// ErrPartialFailure is wrapped by Result.Err when some tokens failed.
+	ErrPartialFailure = errors.New("vwap calculation failed for some tokens")
+)
+
+// TokenStatus tells what happened to a token during a VWAP run.
+type TokenStatus string
+
+const (
+	TokenSuccess      TokenStatus = "success"
+	TokenSkipped      TokenStatus = "skipped"
+	TokenParseError   TokenStatus = "parse_error"
+	TokenStorageError TokenStatus = "storage_error"
+)
+
+// TokenResult is the outcome of a VWAP run for a token.
+type TokenResult struct {
+	Token  string
+	Status TokenStatus
+	// Prices holds the stored price of each aggregator, keyed by aggregator name.
+	// It holds the carried forward prices when Status is TokenSkipped, and the
+	// prices of the parsed swaps when Status is TokenParseError.
+	Prices map[string]Decimal
+	// Err wraps ErrMissingVolume, ErrParse or ErrStorage unless Status is TokenSuccess.
+	Err error
+}
+
+// Result is the outcome of a VWAP run, with an entry for every token of the source.
+type Result struct {
+	Tokens map[string]TokenResult
+	// RunID is the run ID of the stored rows, see VWAPData.
+	RunID string
+}
+
+func newResult() Result {
+	return Result{Tokens: make(map[string]TokenResult)}
+}
+
+// fail records the error of a token, keeping the first one.
+func (r Result) fail(token string, err error) {
+	res, ok := r.Tokens[token]
+	if ok && res.Err != nil {
+		return
+	}
+	res.Token = token
+	res.Err = err
+	res.Status = statusOf(err)
+	r.Tokens[token] = res
+}
+
+// succeed records the price of a token calculated with an aggregator.
+func (r Result) succeed(token, aggregator string, price Decimal) {
+	res, ok := r.Tokens[token]
+	if !ok {
+		res = TokenResult{Token: token, Status: TokenSuccess}
+	}
+	if res.Prices == nil {
+		res.Prices = make(map[string]Decimal)
+	}
+	res.Prices[aggregator] = price
+	r.Tokens[token] = res
+}
+
+func statusOf(err error) TokenStatus {
+	switch {
+	case errors.Is(err, ErrMissingVolume):
+		return TokenSkipped
+	case errors.Is(err, ErrParse):
+		return TokenParseError
+	default:
+		return TokenStorageError
+	}
+}
+
+// Prices returns the stored prices keyed by token, then by aggregator name.
+func (r Result) Prices() map[string]map[string]Decimal {
+	prices := make(map[string]map[string]Decimal)
+	for token, res := range r.Tokens {
+		if len(res.Prices) > 0 {
+			prices[token] = res.Prices
+		}
+	}
+	return prices
+}
+
+// Count returns the number of tokens with the given status.
+func (r Result) Count(status TokenStatus) int {
+	n := 0
+	for _, res := range r.Tokens {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error wrapping ErrPartialFailure and the error of every token that
+// failed to parse or store, or nil if there is none. Skipped tokens are not failures.
+func (r Result) Err() error {
+	var (
+		tokens []string
+		errs   []error
+	)
+	for token, res := range r.Tokens {
+		if res.Status == TokenParseError || res.Status == TokenStorageError {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		errs = append(errs, r.Tokens[token].Err)
+	}
+	return fmt.Errorf("%w (%d of %d tokens): %w", ErrPartialFailure, len(tokens), len(r.Tokens), errors.Join(errs...))
+}
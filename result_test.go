@@ -0,0 +1,76 @@
+package vwap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestVWAPReportsTokenStatus(t *testing.T) {
+	db := newTestDB(t)
+	// bar was priced by an earlier run
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "gno.land/r/demo/bar", aggregator: AggregatorVWAP},
+		Window10m, MustParseDecimal("30.5"), MustParseDecimal("61"), time.Now().Add(-time.Hour), StatusComputed)}))
+
+	// a swap of qux without a USD value
+	malformed := usdcSwap("0x3", "gno.land/r/demo/qux", "2", "10", time.Now())
+	malformed.TokenB, malformed.TotalUsd = SwapToken{Path: "gno.land/r/demo/wugnot"}, "n/a"
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now()),
+		usdcSwap("0x2", "gno.land/r/demo/baz", "lots", "10", time.Now()),
+		malformed,
+	})
+
+	calculator := NewCalculator(db, source, Config{})
+	assert.NoError(t, calculator.RestoreLastPrices(context.Background()))
+	result, err := calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result.Tokens, 6)
+
+	assert.Equal(t, TokenSuccess, result.Tokens["gno.land/r/demo/foo"].Status)
+	assert.Equal(t, "1.25", result.Tokens["gno.land/r/demo/foo"].Prices[AggregatorVWAP].String())
+	assert.Equal(t, TokenSkipped, result.Tokens["gno.land/r/demo/bar"].Status)
+	assert.True(t, errors.Is(result.Tokens["gno.land/r/demo/bar"].Err, ErrMissingVolume))
+	assert.Equal(t, "30.5", result.Tokens["gno.land/r/demo/bar"].Prices[AggregatorVWAP].String())
+	assert.Equal(t, 1, result.Count(TokenSkipped))
+
+	// every token of a malformed swap is reported, including usdc, which is
+	// still priced from its other swap
+	for _, token := range []string{"gno.land/r/demo/baz", "gno.land/r/demo/qux", "gno.land/r/demo/wugnot", "gno.land/r/demo/usdc"} {
+		assert.Equal(t, TokenParseError, result.Tokens[token].Status, token)
+		assert.True(t, errors.Is(result.Tokens[token].Err, ErrParse), token)
+	}
+	assert.Equal(t, "1", result.Tokens["gno.land/r/demo/usdc"].Prices[AggregatorVWAP].String())
+	assert.Equal(t, 4, result.Count(TokenParseError))
+
+	err = result.Err()
+	assert.True(t, errors.Is(err, ErrPartialFailure))
+	assert.True(t, errors.Is(err, ErrParse))
+	assert.False(t, errors.Is(err, ErrMissingVolume))
+}
+
+func TestVWAPReportsStorageErrors(t *testing.T) {
+	// the table is missing
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	source := NewMemorySource(nil, []Swap{usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now())})
+	result, err := NewCalculator(db, source, Config{}).VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, TokenStorageError, result.Tokens["gno.land/r/demo/foo"].Status)
+	assert.True(t, errors.Is(result.Err(), ErrStorage))
+	assert.Empty(t, result.Prices())
+}
+
+func TestResultErrWithoutFailures(t *testing.T) {
+	result := newResult()
+	result.succeed("foo", AggregatorVWAP, MustParseDecimal("1"))
+	result.fail("bar", ErrMissingVolume)
+	assert.NoError(t, result.Err())
+}
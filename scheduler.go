@@ -0,0 +1,80 @@
+package vwap
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler runs a job at a fixed interval aligned to wall-clock buckets,
+// e.g. at :00, :10, :20 for a 10 minute interval.
+type Scheduler struct {
+	interval time.Duration
+	job      func(ctx context.Context, tick time.Time)
+	now      func() time.Time
+
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running bool
+}
+
+// NewScheduler returns a scheduler calling job at every bucket boundary.
+// The job receives the boundary time it was scheduled for, and the context
+// of Run so that it can abort once the scheduler is stopped.
+func NewScheduler(interval time.Duration, job func(ctx context.Context, tick time.Time)) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+		job:      job,
+		now:      time.Now,
+	}
+}
+
+// NextTick returns the first bucket boundary strictly after t.
+func NextTick(t time.Time, interval time.Duration) time.Time {
+	return t.Truncate(interval).Add(interval)
+}
+
+// Run blocks until ctx is done, calling the job at every bucket boundary.
+// A tick is skipped if the previous job is still running. Once ctx is done,
+// Run waits for the in-flight job to return before returning; since the job
+// gets the same ctx, it is cancelled rather than run to completion.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.wg.Wait()
+
+	for {
+		tick := NextTick(s.now(), s.interval)
+		timer := time.NewTimer(tick.Sub(s.now()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.start(ctx, tick)
+		}
+	}
+}
+
+func (s *Scheduler) start(ctx context.Context, tick time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		log.Printf("skipping tick %s: previous run still in progress\n", tick.Format(time.RFC3339))
+		return
+	}
+	s.running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			s.running = false
+			s.mu.Unlock()
+		}()
+
+		s.job(ctx, tick)
+	}()
+}
@@ -0,0 +1,77 @@
+package vwap
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextTick(t *testing.T) {
+	at := time.Date(2024, 5, 16, 5, 21, 17, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 30, 0, 0, time.UTC), NextTick(at, Window10m))
+	assert.Equal(t, time.Date(2024, 5, 16, 6, 0, 0, 0, time.UTC), NextTick(at, Window1h))
+
+	// a time on a boundary schedules the next one
+	boundary := time.Date(2024, 5, 16, 5, 20, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 30, 0, 0, time.UTC), NextTick(boundary, Window10m))
+}
+
+func TestSchedulerRunsAlignedTicks(t *testing.T) {
+	ticks := make(chan time.Time, 10)
+	scheduler := NewScheduler(20*time.Millisecond, func(_ context.Context, tick time.Time) {
+		ticks <- tick
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case tick := <-ticks:
+			assert.Equal(t, tick, tick.Truncate(20*time.Millisecond))
+		case <-time.After(time.Second):
+			t.Fatal("scheduler did not tick")
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSchedulerWaitsForInFlightJob(t *testing.T) {
+	var finished atomic.Bool
+	started := make(chan struct{}, 1)
+
+	scheduler := NewScheduler(10*time.Millisecond, func(context.Context, time.Time) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not start the job")
+	}
+
+	cancel()
+	<-done
+	assert.True(t, finished.Load(), "Run returned before the in-flight job finished")
+}
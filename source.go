@@ -0,0 +1,229 @@
+package vwap
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TradeSource provides the swaps used to calculate VWAP.
+//
+// The sources below also serve the token price snapshots of the API with a
+// TokenPrices method. VWAP does not use them.
+type TradeSource interface {
+	// Swaps returns the individual swaps known to the source.
+	Swaps(ctx context.Context) ([]Swap, error)
+}
+
+// GnoswapSource reads market data from the Gnoswap REST API.
+type GnoswapSource struct {
+	PriceEndpoint    string
+	ActivityEndpoint string
+	// Client calls the API. Defaults to DefaultClient.
+	Client *Client
+}
+
+// NewGnoswapSource returns a source for the Gnoswap dev API, with its own client.
+func NewGnoswapSource() *GnoswapSource {
+	return &GnoswapSource{
+		PriceEndpoint:    PriceEndpoint,
+		ActivityEndpoint: ActivitySwapEndpoint,
+		Client:           NewClient(ClientConfig{}),
+	}
+}
+
+func (s *GnoswapSource) TokenPrices(ctx context.Context) ([]TokenPrice, error) {
+	return fetchTokenPrices(ctx, s.client(), s.PriceEndpoint)
+}
+
+func (s *GnoswapSource) Swaps(ctx context.Context) ([]Swap, error) {
+	return fetchActivity(ctx, s.client(), fmt.Sprintf(s.ActivityEndpoint, QueryTypeSwap))
+}
+
+func (s *GnoswapSource) client() *Client {
+	if s.Client == nil {
+		return DefaultClient
+	}
+	return s.Client
+}
+
+// FileSource replays recorded market data from files.
+//
+// JSON files use the same shape as the API responses (PricesResponse and
+// ActivitySwapResponse). CSV files must have a header row whose column names
+// match the JSON field names, e.g. "path,usd,volumeUsd24h" for prices and
+// "time,tokenA,tokenAAmount,tokenB,tokenBAmount,totalUsd,account" for swaps.
+// Swaps may also have "txHash", "tokenAPath" and "tokenBPath" columns.
+// An empty path yields no data.
+type FileSource struct {
+	PricesPath string
+	SwapsPath  string
+}
+
+func (s *FileSource) TokenPrices(ctx context.Context) ([]TokenPrice, error) {
+	if s.PricesPath == "" {
+		return nil, nil
+	}
+
+	if isCSV(s.PricesPath) {
+		rows, err := readCSV(s.PricesPath)
+		if err != nil {
+			return nil, err
+		}
+
+		prices := make([]TokenPrice, 0, len(rows))
+		for _, row := range rows {
+			prices = append(prices, TokenPrice{
+				Path:              row["path"],
+				USD:               row["usd"],
+				MarketCap:         row["marketCap"],
+				LockedTokensUSD:   row["lockedTokensUsd"],
+				VolumeUSD24h:      row["volumeUsd24h"],
+				FeeUSD24h:         row["feeUsd24h"],
+				MostLiquidityPool: row["mostLiquidityPool"],
+			})
+		}
+		return prices, nil
+	}
+
+	var response PricesResponse
+	if err := readJSON(s.PricesPath, &response); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (s *FileSource) Swaps(ctx context.Context) ([]Swap, error) {
+	if s.SwapsPath == "" {
+		return nil, nil
+	}
+
+	if isCSV(s.SwapsPath) {
+		rows, err := readCSV(s.SwapsPath)
+		if err != nil {
+			return nil, err
+		}
+
+		swaps := make([]Swap, 0, len(rows))
+		for _, row := range rows {
+			swaps = append(swaps, Swap{
+				TxHash:       row["txHash"],
+				Time:         row["time"],
+				TokenA:       SwapToken{Symbol: row["tokenA"], Path: row["tokenAPath"]},
+				TokenAAmount: row["tokenAAmount"],
+				TokenB:       SwapToken{Symbol: row["tokenB"], Path: row["tokenBPath"]},
+				TokenBAmount: row["tokenBAmount"],
+				TotalUsd:     row["totalUsd"],
+				Account:      row["account"],
+			})
+		}
+		return swaps, nil
+	}
+
+	var response ActivitySwapResponse
+	if err := readJSON(s.SwapsPath, &response); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func isCSV(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+func readJSON(path string, v any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return nil
+}
+
+// readCSV reads a CSV file with a header row and returns each record keyed by column name.
+func readCSV(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read header of %s: %v", path, err)
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[strings.TrimSpace(column)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// MemorySource serves market data held in memory. It is safe for concurrent use
+// and is mainly intended for tests.
+type MemorySource struct {
+	mu     sync.Mutex
+	prices []TokenPrice
+	swaps  []Swap
+}
+
+func NewMemorySource(prices []TokenPrice, swaps []Swap) *MemorySource {
+	s := &MemorySource{}
+	s.SetTokenPrices(prices)
+	s.SetSwaps(swaps)
+	return s
+}
+
+func (s *MemorySource) SetTokenPrices(prices []TokenPrice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices = append([]TokenPrice(nil), prices...)
+}
+
+func (s *MemorySource) SetSwaps(swaps []Swap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.swaps = append([]Swap(nil), swaps...)
+}
+
+func (s *MemorySource) TokenPrices(ctx context.Context) ([]TokenPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]TokenPrice(nil), s.prices...), nil
+}
+
+func (s *MemorySource) Swaps(ctx context.Context) ([]Swap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Swap(nil), s.swaps...), nil
+}
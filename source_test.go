@@ -0,0 +1,133 @@
+package vwap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	writeTo(t, path, content)
+	return path
+}
+
+func writeTo(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestGnoswapSource(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prices":
+			_ = json.NewEncoder(w).Encode(PricesResponse{Data: []TokenPrice{{Path: "gno.land/r/demo/bar", USD: "1.5"}}})
+		case "/activity":
+			assert.Equal(t, QueryTypeSwap, r.URL.Query().Get("type"))
+			_ = json.NewEncoder(w).Encode(ActivitySwapResponse{Data: []Swap{{Time: "2024-05-16 05:21:17", TotalUsd: "10"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &GnoswapSource{
+		PriceEndpoint:    server.URL + "/prices",
+		ActivityEndpoint: server.URL + "/activity?type=%s",
+	}
+
+	prices, err := source.TokenPrices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []TokenPrice{{Path: "gno.land/r/demo/bar", USD: "1.5"}}, prices)
+
+	swaps, err := source.Swaps(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Swap{{Time: "2024-05-16 05:21:17", TotalUsd: "10"}}, swaps)
+}
+
+func TestFileSourceJSON(t *testing.T) {
+	source := &FileSource{
+		PricesPath: writeFile(t, "prices.json", `{"data":[{"path":"gno.land/r/demo/foo","usd":"2.5","volumeUsd24h":"100"}]}`),
+		SwapsPath:  writeFile(t, "swaps.json", `{"data":[{"time":"2024-05-16 05:21:17","tokenA":{"symbol":"GNS"},"tokenAAmount":"10","tokenB":{"symbol":"GNOT"},"tokenBAmount":"-5","totalUsd":"20"}]}`),
+	}
+
+	prices, err := source.TokenPrices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []TokenPrice{{Path: "gno.land/r/demo/foo", USD: "2.5", VolumeUSD24h: "100"}}, prices)
+
+	swaps, err := source.Swaps(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Swap{{
+		Time:         "2024-05-16 05:21:17",
+		TokenA:       SwapToken{Symbol: "GNS"},
+		TokenAAmount: "10",
+		TokenB:       SwapToken{Symbol: "GNOT"},
+		TokenBAmount: "-5",
+		TotalUsd:     "20",
+	}}, swaps)
+}
+
+func TestFileSourceCSV(t *testing.T) {
+	source := &FileSource{
+		PricesPath: writeFile(t, "prices.csv", "path,usd,volumeUsd24h\ngno.land/r/demo/foo,2.5,100\ngno.land/r/demo/bar,3,0\n"),
+		SwapsPath:  writeFile(t, "swaps.csv", "time,tokenA,tokenAAmount,tokenB,tokenBAmount,totalUsd\n2024-05-16 05:21:17,GNS,10,GNOT,-5,20\n"),
+	}
+
+	prices, err := source.TokenPrices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []TokenPrice{
+		{Path: "gno.land/r/demo/foo", USD: "2.5", VolumeUSD24h: "100"},
+		{Path: "gno.land/r/demo/bar", USD: "3", VolumeUSD24h: "0"},
+	}, prices)
+
+	swaps, err := source.Swaps(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Swap{{
+		Time:         "2024-05-16 05:21:17",
+		TokenA:       SwapToken{Symbol: "GNS"},
+		TokenAAmount: "10",
+		TokenB:       SwapToken{Symbol: "GNOT"},
+		TokenBAmount: "-5",
+		TotalUsd:     "20",
+	}}, swaps)
+}
+
+func TestFileSourceMissingFile(t *testing.T) {
+	source := &FileSource{PricesPath: filepath.Join(t.TempDir(), "missing.json")}
+
+	_, err := source.TokenPrices(context.Background())
+	assert.Error(t, err)
+
+	swaps, err := source.Swaps(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, swaps)
+}
+
+func TestMemorySource(t *testing.T) {
+	prices := []TokenPrice{{Path: "gno.land/r/demo/foo", USD: "1"}}
+	source := NewMemorySource(prices, nil)
+
+	got, err := source.TokenPrices(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, prices, got)
+
+	// returned slices must not alias the internal state
+	got[0].USD = "2"
+	got, _ = source.TokenPrices(context.Background())
+	assert.Equal(t, "1", got[0].USD)
+
+	source.SetSwaps([]Swap{{TotalUsd: "5"}})
+	swaps, err := source.Swaps(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Swap{{TotalUsd: "5"}}, swaps)
+}
@@ -1,58 +1,233 @@
 package vwap
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/bxcodec/faker/v3"
 	_ "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-/* Schema:
-* CREATE TABLE vwap_data (
-*     id SERIAL PRIMARY KEY,
-*     token_name VARCHAR(50) NOT NULL,
-*     calculated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-*     vwap DECIMAL(20, 10) NOT NULL,
-*     total_volume DECIMAL(20, 10) NOT NULL
-* );
- */
+// The schema of vwap_data is defined by the migrations, see migrate.go.
 
+// VWAPStatus tells how a stored VWAP was obtained.
+type VWAPStatus string
+
+const (
+	// StatusComputed marks a VWAP calculated from the trades of the tick.
+	StatusComputed VWAPStatus = "computed"
+	// StatusCarriedForward marks a tick without volume that repeats the last known price.
+	StatusCarriedForward VWAPStatus = "carried_forward"
+	// StatusNoData marks a tick without volume for a token that was never priced.
+	StatusNoData VWAPStatus = "no_data"
+)
+
+// VWAPData is a stored price of a series. CalculatedAt is rounded down to the
+// window, so that a series has at most one row per bucket of its window.
 type VWAPData struct {
-	gorm.Model
-	TokenName    string
-	VWAP         float64
-	TotalVolume  float64
-	CalculatedAt time.Time
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	TokenName  string `gorm:"size:255;not null;uniqueIndex:idx_vwap_bucket"`
+	Pair       string `gorm:"size:255;not null;default:'';uniqueIndex:idx_vwap_bucket"`
+	Aggregator string `gorm:"size:20;not null;default:'vwap';uniqueIndex:idx_vwap_bucket"`
+	// Window is the bucket size, e.g. "10m". The column avoids the WINDOW keyword.
+	Window       string `gorm:"column:window_size;size:10;not null;default:'10m';uniqueIndex:idx_vwap_bucket"`
+	VWAP         Decimal
+	TotalVolume  Decimal
+	CalculatedAt time.Time  `gorm:"not null;uniqueIndex:idx_vwap_bucket"`
+	Status       VWAPStatus `gorm:"size:20"`
+	// RunID is shared by the rows stored together by a run, see storeRun.
+	RunID string `gorm:"size:32;not null;default:'';index"`
+}
+
+// windowName formats a window the way it is stored, e.g. "10m", "1h" or "1d".
+func windowName(window time.Duration) string {
+	switch {
+	case window%Window24h == 0:
+		return fmt.Sprintf("%dd", window/Window24h)
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	default:
+		return window.String()
+	}
+}
+
+// vwapBucket is the unique key of VWAPData.
+var vwapBucket = []clause.Column{{Name: "token_name"}, {Name: "pair"}, {Name: "aggregator"}, {Name: "window_size"}, {Name: "calculated_at"}}
+
+// upsertVWAPData makes a row replace the row already stored for its bucket.
+var upsertVWAPData = clause.OnConflict{
+	Columns:   vwapBucket,
+	DoUpdates: clause.AssignmentColumns([]string{"updated_at", "vwap", "total_volume", "status", "run_id"}),
+}
+
+// storeBatchSize is the number of rows inserted per statement by storeRun.
+const storeBatchSize = 100
+
+// OpenDB connects to the database using the named driver ("mysql" or "sqlite").
+func OpenDB(driver, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case "mysql":
+		dialector = mysql.Open(dsn)
+	case "sqlite":
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %v", err)
+	}
+
+	return db, nil
 }
 
-func store(db *gorm.DB, tokenName string, vwap, totalVolume float64, calculatedAt time.Time) error {
-	vwapData := VWAPData{
-		TokenName:    tokenName,
+// newVWAPData returns the row of the series for the bucket of the window holding
+// calculatedAt. Rows of a pair keep the pair's base token as TokenName.
+func newVWAPData(key seriesKey, window time.Duration, vwap, totalVolume Decimal, calculatedAt time.Time, status VWAPStatus) VWAPData {
+	return VWAPData{
+		TokenName:    key.tokenName,
+		Pair:         key.pair,
+		Aggregator:   key.aggregator,
+		Window:       windowName(window),
 		VWAP:         vwap,
 		TotalVolume:  totalVolume,
-		CalculatedAt: calculatedAt,
+		CalculatedAt: calculatedAt.UTC().Truncate(window),
+		Status:       status,
 	}
+}
 
-	result := db.Create(&vwapData)
-	if result.Error != nil {
-		return fmt.Errorf("failed to insert data: %v", result.Error)
+// storeRun stores the rows of a run with the given run ID, in batches but within a
+// single transaction: either every row is stored or none is. Each row replaces the
+// row already stored for its bucket, e.g. by a retried tick.
+func storeRun(ctx context.Context, db *gorm.DB, runID string, rows []VWAPData) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	for i := range rows {
+		rows[i].RunID = runID
+	}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(upsertVWAPData).CreateInBatches(&rows, storeBatchSize).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert run %s: %v", runID, err)
 	}
 
 	return nil
 }
 
+// storeSeriesOnce stores the row of the series for the bucket of the window holding
+// calculatedAt unless one is already stored. It reports whether the row was stored.
+func storeSeriesOnce(ctx context.Context, db *gorm.DB, key seriesKey, window time.Duration, vwap, totalVolume Decimal, calculatedAt time.Time, status VWAPStatus) (bool, error) {
+	vwapData := newVWAPData(key, window, vwap, totalVolume, calculatedAt, status)
+
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   vwapBucket,
+		DoNothing: true,
+	}).Create(&vwapData)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to insert data: %v", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// latestSeriesAt returns the most recent row of the series calculated at or before t.
+// Times are stored in UTC, and compared as text by SQLite, so t is converted to UTC.
+func latestSeriesAt(ctx context.Context, db *gorm.DB, key seriesKey, t time.Time) (VWAPData, bool, error) {
+	var rows []VWAPData
+	result := db.WithContext(ctx).
+		Where("token_name = ? AND pair = ? AND aggregator = ? AND calculated_at <= ?", key.tokenName, key.pair, key.aggregator, t.UTC()).
+		Order("calculated_at DESC, id DESC").
+		Limit(1).
+		Find(&rows)
+	if result.Error != nil {
+		return VWAPData{}, false, fmt.Errorf("failed to query data: %v", result.Error)
+	}
+	if len(rows) == 0 {
+		return VWAPData{}, false, nil
+	}
+	return rows[0], true, nil
+}
+
+// latestSeries returns the most recent priced row of the series. Rows of ticks
+// without a known price (StatusNoData) are skipped.
+func latestSeries(ctx context.Context, db *gorm.DB, key seriesKey) (VWAPData, error) {
+	var vwapData VWAPData
+	result := db.WithContext(ctx).Where("token_name = ? AND pair = ? AND aggregator = ? AND status <> ?", key.tokenName, key.pair, key.aggregator, StatusNoData).
+		Order("calculated_at DESC, id DESC").
+		First(&vwapData)
+	if result.Error != nil {
+		return VWAPData{}, result.Error
+	}
+	return vwapData, nil
+}
+
+// latestVWAPs returns the most recent priced row of every series, skipping
+// StatusNoData rows like latestSeries, ordered by token name, pair and aggregator.
+func latestVWAPs(ctx context.Context, db *gorm.DB) ([]VWAPData, error) {
+	db = db.WithContext(ctx)
+	latest := db.Model(&VWAPData{}).
+		Select("token_name, pair, aggregator, MAX(calculated_at)").
+		Where("status <> ?", StatusNoData).
+		Group("token_name, pair, aggregator")
+
+	var rows []VWAPData
+	result := db.Where("status <> ? AND (token_name, pair, aggregator, calculated_at) IN (?)", StatusNoData, latest).
+		Order("token_name, pair, aggregator, id DESC").
+		Find(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query latest data: %v", result.Error)
+	}
+
+	// keep a single row per series if several share the same timestamp
+	unique := rows[:0]
+	for _, row := range rows {
+		if n := len(unique); n > 0 && seriesKeyOf(unique[n-1]) == seriesKeyOf(row) {
+			continue
+		}
+		unique = append(unique, row)
+	}
+	return unique, nil
+}
+
+// vwapHistory returns the rows of the series calculated within [from, to], oldest first.
+// Like in latestSeriesAt, from and to are converted to UTC.
+func vwapHistory(ctx context.Context, db *gorm.DB, key seriesKey, from, to time.Time) ([]VWAPData, error) {
+	var rows []VWAPData
+	result := db.WithContext(ctx).Where("token_name = ? AND pair = ? AND aggregator = ? AND calculated_at BETWEEN ? AND ?",
+		key.tokenName, key.pair, key.aggregator, from.UTC(), to.UTC()).
+		Order("calculated_at, id").
+		Find(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query history: %v", result.Error)
+	}
+	return rows, nil
+}
+
 // testing purpose
 
 func PopulateVWAPData(db *gorm.DB, count int) error {
 	for i := 0; i < count; i++ {
 		vwapData := VWAPData{
 			TokenName:    faker.Currency(),
-			VWAP:         rand.Float64(),
-			TotalVolume:  rand.Float64(),
+			VWAP:         NewDecimalFromFloat(rand.Float64()),
+			TotalVolume:  NewDecimalFromFloat(rand.Float64()),
 			CalculatedAt: time.Now().Add(time.Duration(rand.Intn(1000)) * time.Minute),
+			Status:       StatusComputed,
 		}
 
 		result := db.Create(&vwapData)
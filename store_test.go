@@ -1,6 +1,7 @@
 package vwap
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -15,12 +16,12 @@ func TestStoreWithGORM(t *testing.T) {
 		t.Fatalf("failed to connect database: %v", err)
 	}
 
-	err = db.AutoMigrate(&VWAPData{})
+	_, err = Migrate(context.Background(), db)
 	if err != nil {
 		t.Fatalf("failed to migrate database: %v", err)
 	}
 
-	err = store(db, "FOO", 50000.0, 1000.0, time.Now())
+	err = storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "FOO", aggregator: AggregatorVWAP}, Window10m, MustParseDecimal("50000.123456789012345678"), MustParseDecimal("1000"), time.Now(), StatusComputed)})
 	if err != nil {
 		t.Errorf("error was not expected while storing data: %s", err)
 	}
@@ -28,7 +29,7 @@ func TestStoreWithGORM(t *testing.T) {
 	var vwapData VWAPData
 	db.First(&vwapData)
 
-	if vwapData.TokenName != "FOO" || vwapData.VWAP != 50000.0 || vwapData.TotalVolume != 1000.0 {
+	if vwapData.TokenName != "FOO" || vwapData.VWAP.String() != "50000.123456789012345678" || vwapData.TotalVolume.String() != "1000" {
 		t.Errorf("stored data is incorrect")
 	}
 }
@@ -37,21 +38,22 @@ func TestVWAPWith10MinuteInterval(t *testing.T) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	assert.NoError(t, err)
 
-	err = db.AutoMigrate(&VWAPData{})
+	_, err = Migrate(context.Background(), db)
 	assert.NoError(t, err)
+	calculator := NewCalculator(db, nil, Config{})
 
 	// Mock trade data with different timestamps
 	trades := []TradeData{
-		{TokenName: "Token1", Volume: 100, Ratio: 1.5, Timestamp: 1621000000},
-		{TokenName: "Token1", Volume: 200, Ratio: 1.8, Timestamp: 1621000180},
-		{TokenName: "Token1", Volume: 150, Ratio: 1.6, Timestamp: 1621000420},
-		{TokenName: "Token1", Volume: 300, Ratio: 1.7, Timestamp: 1621000600},
-		{TokenName: "Token1", Volume: 250, Ratio: 1.9, Timestamp: 1621000900},
+		{TokenName: "Token1", Volume: MustParseDecimal("100"), Ratio: MustParseDecimal("1.5"), Timestamp: 1621000000},
+		{TokenName: "Token1", Volume: MustParseDecimal("200"), Ratio: MustParseDecimal("1.8"), Timestamp: 1621000180},
+		{TokenName: "Token1", Volume: MustParseDecimal("150"), Ratio: MustParseDecimal("1.6"), Timestamp: 1621000420},
+		{TokenName: "Token1", Volume: MustParseDecimal("300"), Ratio: MustParseDecimal("1.7"), Timestamp: 1621000600},
+		{TokenName: "Token1", Volume: MustParseDecimal("250"), Ratio: MustParseDecimal("1.9"), Timestamp: 1621000900},
 	}
 
 	// Calculate VWAP for each 10-minute interval
-	var expectedVWAPs []float64
-	var actualVWAPs []float64
+	var expectedVWAPs []Decimal
+	var actualVWAPs []Decimal
 
 	intervalStart := trades[0].Timestamp
 	var intervalTrades []TradeData
@@ -63,7 +65,8 @@ func TestVWAPWith10MinuteInterval(t *testing.T) {
 			expectedVWAP := calculateExpectedVWAP(intervalTrades)
 			expectedVWAPs = append(expectedVWAPs, expectedVWAP)
 
-			actualVWAP, err := calculateVWAP(db, intervalTrades)
+			calculator.now = tickAt(int64(intervalStart))
+			actualVWAP, err := priceTick(context.Background(), calculator, intervalTrades)
 			assert.Nil(t, err, "Unexpected error")
 
 			actualVWAPs = append(actualVWAPs, actualVWAP)
@@ -77,7 +80,8 @@ func TestVWAPWith10MinuteInterval(t *testing.T) {
 	expectedVWAP := calculateExpectedVWAP(intervalTrades)
 	expectedVWAPs = append(expectedVWAPs, expectedVWAP)
 
-	actualVWAP, err := calculateVWAP(db, intervalTrades)
+	calculator.now = tickAt(int64(intervalStart))
+	actualVWAP, err := priceTick(context.Background(), calculator, intervalTrades)
 	assert.Nil(t, err, "Unexpected error")
 
 	actualVWAPs = append(actualVWAPs, actualVWAP)
@@ -86,7 +90,7 @@ func TestVWAPWith10MinuteInterval(t *testing.T) {
 	assert.Equal(t, len(expectedVWAPs), len(actualVWAPs), "Unexpected number of intervals")
 
 	for i := 0; i < len(expectedVWAPs); i++ {
-		assert.InDelta(t, expectedVWAPs[i], actualVWAPs[i], 1e-9, "Incorrect VWAP for interval %d", i+1)
+		assert.Equal(t, expectedVWAPs[i].String(), actualVWAPs[i].String(), "Incorrect VWAP for interval %d", i+1)
 	}
 
 	var vwapDataList []VWAPData
@@ -96,21 +100,156 @@ func TestVWAPWith10MinuteInterval(t *testing.T) {
 
 	for i, vwapData := range vwapDataList {
 		assert.Equal(t, "Token1", vwapData.TokenName)
-		assert.InDelta(t, expectedVWAPs[i], vwapData.VWAP, 1e-9)
+		assert.Equal(t, expectedVWAPs[i].String(), vwapData.VWAP.String())
 	}
 }
 
-func calculateExpectedVWAP(trades []TradeData) float64 {
-	var numerator, denominator float64
+// priceTick prices the trades of a token like a tick of VWAP and stores the row.
+func priceTick(ctx context.Context, c *Calculator, trades []TradeData) (Decimal, error) {
+	key := seriesKey{tokenName: trades[0].TokenName, aggregator: AggregatorVWAP}
+	row, err := c.price(ctx, c.db, key, trades, VWAPAggregator{}, c.now())
+	if err != nil {
+		return Decimal{}, err
+	}
+	if err := c.commit(ctx, c.db, newRunID(), []VWAPData{row}); err != nil {
+		return Decimal{}, err
+	}
+	return row.VWAP, nil
+}
+
+// tickAt returns a clock stopped at the given Unix time.
+func tickAt(sec int64) func() time.Time {
+	return func() time.Time { return time.Unix(sec, 0) }
+}
+
+func calculateExpectedVWAP(trades []TradeData) Decimal {
+	var numerator, denominator Decimal
 
 	for _, trade := range trades {
-		numerator += trade.Volume * trade.Ratio
-		denominator += trade.Volume
+		numerator = numerator.Add(trade.Volume.Mul(trade.Ratio))
+		denominator = denominator.Add(trade.Volume)
+	}
+
+	if denominator.IsZero() {
+		return Decimal{}
+	}
+
+	return numerator.Quo(denominator)
+}
+
+func TestZeroVolumeTicksAreStored(t *testing.T) {
+	db := newTestDB(t)
+	calculator := NewCalculator(db, nil, Config{})
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC).Unix()
+
+	// never traded: no data
+	calculator.now = tickAt(base)
+	price, err := priceTick(context.Background(), calculator, []TradeData{{TokenName: "Quiet", Volume: MustParseDecimal("0"), Ratio: MustParseDecimal("5")}})
+	assert.NoError(t, err)
+	assert.True(t, price.IsZero())
+
+	calculator.now = tickAt(base + 600)
+	_, err = priceTick(context.Background(), calculator, []TradeData{{TokenName: "Quiet", Volume: MustParseDecimal("10"), Ratio: MustParseDecimal("2.5")}})
+	assert.NoError(t, err)
+
+	// quiet tick after a trade: carried forward
+	calculator.now = tickAt(base + 1200)
+	price, err = priceTick(context.Background(), calculator, []TradeData{{TokenName: "Quiet"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.5", price.String())
+
+	var rows []VWAPData
+	db.Order("id").Find(&rows)
+	assert.Equal(t, 3, len(rows))
+
+	assert.Equal(t, StatusNoData, rows[0].Status)
+	assert.True(t, rows[0].VWAP.IsZero())
+
+	assert.Equal(t, StatusComputed, rows[1].Status)
+	assert.Equal(t, "2.5", rows[1].VWAP.String())
+
+	assert.Equal(t, StatusCarriedForward, rows[2].Status)
+	assert.Equal(t, "2.5", rows[2].VWAP.String())
+	assert.True(t, rows[2].TotalVolume.IsZero())
+}
+
+func TestQuietTicksWithoutPriceStayNoData(t *testing.T) {
+	db := newTestDB(t)
+	calculator := NewCalculator(db, nil, Config{})
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC).Unix()
+
+	// two quiet ticks in a row of a token that was never priced
+	for i := int64(0); i < 2; i++ {
+		calculator.now = tickAt(base + i*600)
+		price, err := priceTick(context.Background(), calculator, []TradeData{{TokenName: "Quiet"}})
+		assert.NoError(t, err)
+		assert.True(t, price.IsZero())
 	}
 
-	if denominator == 0 {
-		return 0
+	// a restarted calculator does not restore the no data rows as a price either
+	calculator = NewCalculator(db, nil, Config{})
+	assert.NoError(t, calculator.RestoreLastPrices(context.Background()))
+	calculator.now = tickAt(base + 1200)
+	_, err := priceTick(context.Background(), calculator, []TradeData{{TokenName: "Quiet"}})
+	assert.NoError(t, err)
+
+	var rows []VWAPData
+	db.Order("id").Find(&rows)
+	assert.Equal(t, 3, len(rows))
+	for _, row := range rows {
+		assert.Equal(t, StatusNoData, row.Status)
 	}
 
-	return numerator / denominator
+	_, err = latestSeries(context.Background(), db, seriesKey{tokenName: "Quiet", aggregator: AggregatorVWAP})
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	latest, err := latestVWAPs(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(latest))
+}
+
+func TestRetriedTickReplacesRow(t *testing.T) {
+	db := newTestDB(t)
+	calculator := NewCalculator(db, nil, Config{})
+	tick := time.Date(2024, 5, 16, 5, 10, 0, 0, time.UTC)
+
+	calculator.now = func() time.Time { return tick.Add(time.Second) }
+	_, err := priceTick(context.Background(), calculator, []TradeData{{TokenName: "GNS", Volume: MustParseDecimal("10"), Ratio: MustParseDecimal("2")}})
+	assert.NoError(t, err)
+
+	// retried within the same bucket
+	calculator.now = func() time.Time { return tick.Add(time.Minute) }
+	_, err = priceTick(context.Background(), calculator, []TradeData{{TokenName: "GNS", Volume: MustParseDecimal("10"), Ratio: MustParseDecimal("3")}})
+	assert.NoError(t, err)
+
+	var rows []VWAPData
+	db.Find(&rows)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "3", rows[0].VWAP.String())
+	assert.Equal(t, "10m", rows[0].Window)
+	assert.Equal(t, tick, rows[0].CalculatedAt.UTC())
+
+	// rows of another window do not collide
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "GNS", aggregator: AggregatorVWAP}, Window1h, MustParseDecimal("2.5"), MustParseDecimal("20"), tick, StatusComputed)}))
+	var count int64
+	db.Model(&VWAPData{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestSeriesQueriesConvertTimesToUTC(t *testing.T) {
+	db := newTestDB(t)
+	key := seriesKey{tokenName: "GNS", aggregator: AggregatorVWAP}
+	tick := time.Date(2024, 5, 16, 5, 10, 0, 0, time.UTC)
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(key, Window10m, MustParseDecimal("2"), MustParseDecimal("10"), tick, StatusComputed)}))
+
+	// the same instants, ahead of UTC
+	seoul := time.FixedZone("KST", 9*60*60)
+
+	rows, err := vwapHistory(context.Background(), db, key, tick.Add(-time.Minute).In(seoul), tick.Add(time.Minute).In(seoul))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rows))
+
+	row, ok, err := latestSeriesAt(context.Background(), db, key, tick.In(seoul))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", row.VWAP.String())
 }
@@ -0,0 +1,182 @@
+package vwap
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Errors wrapped by SwapParseError.
+var (
+	ErrInvalidTime   = errors.New("invalid time")
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrZeroAmount    = errors.New("zero amount")
+	ErrMissingToken  = errors.New("missing token")
+	ErrSameToken     = errors.New("swap of a token against itself")
+)
+
+// swapTimeLayouts lists the time formats accepted in Swap.Time.
+var swapTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// SwapParseError reports a malformed field of a swap returned by the activity API.
+// TokenA and TokenB are the token IDs of the swap, empty if missing.
+type SwapParseError struct {
+	TxHash string
+	TokenA string
+	TokenB string
+	Field  string
+	Value  string
+	Err    error
+}
+
+func (e *SwapParseError) Error() string {
+	return fmt.Sprintf("swap %s: field %s (%q): %v", e.TxHash, e.Field, e.Value, e.Err)
+}
+
+func (e *SwapParseError) Unwrap() error {
+	return e.Err
+}
+
+// Direction tells which token of a swap the trader sold.
+type Direction string
+
+const (
+	// DirectionAToB means TokenA was paid into the pool and TokenB received.
+	DirectionAToB Direction = "a_to_b"
+	// DirectionBToA means TokenB was paid into the pool and TokenA received.
+	DirectionBToA Direction = "b_to_a"
+)
+
+// ParsedSwap is a validated Swap with typed fields.
+// Amounts are absolute; the sign of the raw amounts is kept in Direction.
+type ParsedSwap struct {
+	TxHash    string
+	Time      time.Time
+	Account   string
+	TokenA    SwapToken
+	AmountA   Decimal
+	TokenB    SwapToken
+	AmountB   Decimal
+	TotalUsd  Decimal
+	Direction Direction
+}
+
+// TokenIn returns the token sold by the trader and its amount.
+func (s ParsedSwap) TokenIn() (SwapToken, Decimal) {
+	if s.Direction == DirectionBToA {
+		return s.TokenB, s.AmountB
+	}
+	return s.TokenA, s.AmountA
+}
+
+// TokenOut returns the token bought by the trader and its amount.
+func (s ParsedSwap) TokenOut() (SwapToken, Decimal) {
+	if s.Direction == DirectionBToA {
+		return s.TokenA, s.AmountA
+	}
+	return s.TokenB, s.AmountB
+}
+
+// ParseSwap validates a swap and converts its fields.
+//
+// A negative amount is received by the trader. Since the API may send unsigned
+// amounts, TokenA is considered sold unless only its amount is negative.
+// Errors are of type *SwapParseError.
+func ParseSwap(swap Swap) (ParsedSwap, error) {
+	fail := func(field, value string, err error) (ParsedSwap, error) {
+		return ParsedSwap{}, &SwapParseError{TxHash: swap.TxHash, TokenA: swap.TokenA.ID(), TokenB: swap.TokenB.ID(), Field: field, Value: value, Err: err}
+	}
+
+	ts, err := parseSwapTime(swap.Time)
+	if err != nil {
+		return fail("time", swap.Time, ErrInvalidTime)
+	}
+
+	if swap.TokenA.ID() == "" {
+		return fail("tokenA", "", ErrMissingToken)
+	}
+	if swap.TokenB.ID() == "" {
+		return fail("tokenB", "", ErrMissingToken)
+	}
+	if swap.TokenA.ID() == swap.TokenB.ID() {
+		return fail("tokenB", swap.TokenB.ID(), ErrSameToken)
+	}
+
+	amountA, err := ParseDecimal(swap.TokenAAmount)
+	if err != nil {
+		return fail("tokenAAmount", swap.TokenAAmount, fmt.Errorf("%w: %v", ErrInvalidAmount, err))
+	}
+	if amountA.IsZero() {
+		return fail("tokenAAmount", swap.TokenAAmount, ErrZeroAmount)
+	}
+
+	amountB, err := ParseDecimal(swap.TokenBAmount)
+	if err != nil {
+		return fail("tokenBAmount", swap.TokenBAmount, fmt.Errorf("%w: %v", ErrInvalidAmount, err))
+	}
+	if amountB.IsZero() {
+		return fail("tokenBAmount", swap.TokenBAmount, ErrZeroAmount)
+	}
+
+	totalUsd, err := ParseDecimal(swap.TotalUsd)
+	if err != nil || totalUsd.Sign() < 0 {
+		return fail("totalUsd", swap.TotalUsd, ErrInvalidAmount)
+	}
+
+	direction := DirectionAToB
+	if amountA.Sign() < 0 && amountB.Sign() > 0 {
+		direction = DirectionBToA
+	}
+
+	return ParsedSwap{
+		TxHash:    swap.TxHash,
+		Time:      ts,
+		Account:   swap.Account,
+		TokenA:    swap.TokenA,
+		AmountA:   amountA.Abs(),
+		TokenB:    swap.TokenB,
+		AmountB:   amountB.Abs(),
+		TotalUsd:  totalUsd,
+		Direction: direction,
+	}, nil
+}
+
+// ParseSwaps parses every swap. Malformed swaps are left out and their errors returned.
+func ParseSwaps(swaps []Swap) ([]ParsedSwap, []error) {
+	var (
+		parsed []ParsedSwap
+		errs   []error
+	)
+	for _, swap := range swaps {
+		p, err := ParseSwap(swap)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, errs
+}
+
+// parseSwapsLogged parses every swap, logging and skipping malformed ones.
+func parseSwapsLogged(swaps []Swap) []ParsedSwap {
+	parsed, errs := ParseSwaps(swaps)
+	for _, err := range errs {
+		log.Printf("skipping swap: %v\n", err)
+	}
+	return parsed
+}
+
+func parseSwapTime(value string) (time.Time, error) {
+	for _, layout := range swapTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to parse swap time %q", value)
+}
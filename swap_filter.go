@@ -2,26 +2,38 @@ package vwap
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
-	"time"
+	"net/url"
+	"strconv"
 )
 
 // temporary filtering func. should move to a router later.
 
+// SwapToken identifies a token of a swap. The activity API sends amounts in
+// human units, so the token decimals are not needed.
 type SwapToken struct {
 	Symbol string `json:"symbol"`
+	Path   string `json:"path"`
+}
+
+// ID returns the path of the token, or its symbol if the path is unknown.
+func (t SwapToken) ID() string {
+	if t.Path != "" {
+		return t.Path
+	}
+	return t.Symbol
 }
 
 type Swap struct {
+	TxHash       string    `json:"txHash"`
 	Time         string    `json:"time"`
 	TokenA       SwapToken `json:"tokenA"`
 	TokenAAmount string    `json:"tokenAAmount"`
 	TokenB       SwapToken `json:"tokenB"`
 	TokenBAmount string    `json:"tokenBAmount"`
 	TotalUsd     string    `json:"totalUsd"`
+	Account      string    `json:"account"`
 }
 
 type ActivitySwapResponse struct {
@@ -33,31 +45,32 @@ const (
 	QueryTypeSwap        = "SWAP"
 )
 
-func FetchActivitySwap(endpoint, queryType string) ([]Swap, error) {
-	client := &http.Client{}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func FetchActivitySwap(ctx context.Context, endpoint, queryType string) ([]Swap, error) {
+	return fetchActivity(ctx, DefaultClient, fmt.Sprintf(endpoint, queryType))
+}
 
-	rpc := fmt.Sprintf(endpoint, queryType)
-	req, err := http.NewRequestWithContext(ctx, "GET", rpc, nil)
-	if err != nil {
-		return nil, err
-	}
+// FetchActivitySwapPage fetches a page of the activity feed, newest swaps first.
+// Pages are numbered from 1.
+func FetchActivitySwapPage(ctx context.Context, endpoint, queryType string, page, limit int) ([]Swap, error) {
+	return fetchActivitySwapPage(ctx, DefaultClient, endpoint, queryType, page, limit)
+}
 
-	resp, err := client.Do(req)
+func fetchActivitySwapPage(ctx context.Context, client *Client, endpoint, queryType string, page, limit int) ([]Swap, error) {
+	u, err := url.Parse(fmt.Sprintf(endpoint, queryType))
 	if err != nil {
-		log.Printf("error: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("invalid activity endpoint: %v", err)
 	}
-	defer resp.Body.Close()
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error: %v", resp.Status)
-	}
+	return fetchActivity(ctx, client, u.String())
+}
 
+func fetchActivity(ctx context.Context, client *Client, rpc string) ([]Swap, error) {
 	var apiResponse ActivitySwapResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+	if err := client.GetJSON(ctx, rpc, &apiResponse); err != nil {
 		log.Printf("error: %v", err)
 		return nil, err
 	}
@@ -65,20 +78,7 @@ func FetchActivitySwap(endpoint, queryType string) ([]Swap, error) {
 	return apiResponse.Data, nil
 }
 
-var filterToken = map[string]bool{
-	"USDC": true,
-	"GNOT": true,
-	"GNS":  true,
-}
-
+// FilterSwaps keeps the swaps with at least one token enabled in the default registry.
 func FilterSwaps(swaps []Swap) []Swap {
-	filteredSwaps := make([]Swap, 0)
-	for _, swap := range swaps {
-		_, okA := filterToken[swap.TokenA.Symbol]
-		_, okB := filterToken[swap.TokenB.Symbol]
-		if okA || okB {
-			filteredSwaps = append(filteredSwaps, swap)
-		}
-	}
-	return filteredSwaps
+	return DefaultRegistry().FilterSwaps(swaps)
 }
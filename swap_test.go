@@ -0,0 +1,88 @@
+package vwap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSwap(t *testing.T) {
+	swap := Swap{
+		TxHash:       "0xabc",
+		Time:         "2024-05-16 05:21:17",
+		TokenA:       SwapToken{Symbol: "GNS", Path: "gno.land/r/demo/gns"},
+		TokenAAmount: "-100.123456789012345678",
+		TokenB:       SwapToken{Symbol: "GNOT", Path: "gnot"},
+		TokenBAmount: "50",
+		TotalUsd:     "200",
+		Account:      "g1trader",
+	}
+
+	parsed, err := ParseSwap(swap)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xabc", parsed.TxHash)
+	assert.Equal(t, time.Date(2024, 5, 16, 5, 21, 17, 0, time.UTC), parsed.Time)
+	assert.Equal(t, "g1trader", parsed.Account)
+	assert.Equal(t, "100.123456789012345678", parsed.AmountA.String())
+	assert.Equal(t, "50", parsed.AmountB.String())
+	assert.Equal(t, "200", parsed.TotalUsd.String())
+	assert.Equal(t, DirectionBToA, parsed.Direction)
+
+	tokenIn, amountIn := parsed.TokenIn()
+	assert.Equal(t, "gnot", tokenIn.ID())
+	assert.Equal(t, "50", amountIn.String())
+	tokenOut, _ := parsed.TokenOut()
+	assert.Equal(t, "gno.land/r/demo/gns", tokenOut.ID())
+
+	// unsigned amounts default to TokenA being sold
+	swap.TokenAAmount = "100"
+	parsed, err = ParseSwap(swap)
+	assert.NoError(t, err)
+	assert.Equal(t, DirectionAToB, parsed.Direction)
+}
+
+func TestParseSwapErrors(t *testing.T) {
+	valid := Swap{
+		TxHash:       "0xabc",
+		Time:         "2024-05-16T05:21:17Z",
+		TokenA:       SwapToken{Symbol: "GNS"},
+		TokenAAmount: "100",
+		TokenB:       SwapToken{Symbol: "GNOT"},
+		TokenBAmount: "-50",
+		TotalUsd:     "200",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Swap)
+		field  string
+		err    error
+	}{
+		{"invalid time", func(s *Swap) { s.Time = "yesterday" }, "time", ErrInvalidTime},
+		{"missing token", func(s *Swap) { s.TokenA = SwapToken{} }, "tokenA", ErrMissingToken},
+		{"same token", func(s *Swap) { s.TokenB = s.TokenA }, "tokenB", ErrSameToken},
+		{"invalid amount", func(s *Swap) { s.TokenAAmount = "1e5x" }, "tokenAAmount", ErrInvalidAmount},
+		{"zero amount", func(s *Swap) { s.TokenBAmount = "0" }, "tokenBAmount", ErrZeroAmount},
+		{"negative usd", func(s *Swap) { s.TotalUsd = "-1" }, "totalUsd", ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		swap := valid
+		tt.modify(&swap)
+
+		_, err := ParseSwap(swap)
+		assert.ErrorIs(t, err, tt.err, tt.name)
+
+		var parseErr *SwapParseError
+		if assert.True(t, errors.As(err, &parseErr), tt.name) {
+			assert.Equal(t, tt.field, parseErr.Field, tt.name)
+			assert.Equal(t, "0xabc", parseErr.TxHash, tt.name)
+		}
+	}
+
+	parsed, errs := ParseSwaps([]Swap{valid, {TxHash: "0xbad", Time: "invalid"}})
+	assert.Len(t, parsed, 1)
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,160 @@
+package vwap
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// RejectReason tells why a trade was rejected by a TradeFilter.
+type RejectReason string
+
+const (
+	RejectMinVolume RejectReason = "min_volume"
+	RejectSelfTrade RejectReason = "self_trade"
+	RejectDeviation RejectReason = "deviation"
+	RejectTraderCap RejectReason = "trader_cap"
+)
+
+// FilterConfig configures a TradeFilter. A zero value disables the matching check.
+type FilterConfig struct {
+	// MinVolume rejects trades with a smaller USD volume, i.e. the TotalUsd of
+	// their swap, or Volume for trades without one.
+	MinVolume Decimal
+	// RejectSelfTrades rejects round trips: every trade of a token by a trader
+	// who both bought and sold that token within the batch.
+	RejectSelfTrades bool
+	// MaxDeviation rejects trades whose price deviates from the median price of
+	// the batch by more than this fraction, e.g. 0.1 for ±10%.
+	MaxDeviation Decimal
+	// MaxTraderVolume caps the USD volume, counted like MinVolume, that a single
+	// trader may contribute to a token or pair. Trades are counted in time order
+	// and the ones exceeding the cap are rejected.
+	MaxTraderVolume Decimal
+}
+
+// TradeFilter drops suspicious trades before aggregation, so that a single
+// fat-finger or wash swap in a thin pool cannot move the price.
+// Every rejected trade is logged and counted per token.
+type TradeFilter struct {
+	config FilterConfig
+
+	mu       sync.Mutex
+	rejected map[string]map[RejectReason]int
+}
+
+func NewTradeFilter(config FilterConfig) *TradeFilter {
+	return &TradeFilter{
+		config:   config,
+		rejected: make(map[string]map[RejectReason]int),
+	}
+}
+
+// Filter returns the accepted trades, ordered by timestamp. The trades are
+// expected to belong to a single token or pair. A nil filter accepts every trade.
+func (f *TradeFilter) Filter(trades []TradeData) []TradeData {
+	if f == nil {
+		return trades
+	}
+
+	accepted := append([]TradeData(nil), trades...)
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].Timestamp < accepted[j].Timestamp
+	})
+
+	if !f.config.MinVolume.IsZero() {
+		accepted = f.reject(accepted, RejectMinVolume, func(trade TradeData) bool {
+			return usdVolume(trade).Cmp(f.config.MinVolume) < 0
+		})
+	}
+
+	if f.config.RejectSelfTrades {
+		sides := make(map[string]map[Side]bool)
+		for _, trade := range accepted {
+			if trade.Trader == "" || trade.Side == "" {
+				continue
+			}
+			if sides[trade.Trader] == nil {
+				sides[trade.Trader] = make(map[Side]bool)
+			}
+			sides[trade.Trader][trade.Side] = true
+		}
+
+		accepted = f.reject(accepted, RejectSelfTrade, func(trade TradeData) bool {
+			return sides[trade.Trader][SideBuy] && sides[trade.Trader][SideSell]
+		})
+	}
+
+	if !f.config.MaxDeviation.IsZero() && len(accepted) > 0 {
+		reference := median(accepted)
+		accepted = f.reject(accepted, RejectDeviation, func(trade TradeData) bool {
+			if reference.IsZero() {
+				return false
+			}
+			deviation := trade.Ratio.Sub(reference).Abs().Quo(reference)
+			return deviation.Cmp(f.config.MaxDeviation) > 0
+		})
+	}
+
+	if !f.config.MaxTraderVolume.IsZero() {
+		volumes := make(map[string]Decimal)
+		accepted = f.reject(accepted, RejectTraderCap, func(trade TradeData) bool {
+			if trade.Trader == "" {
+				return false
+			}
+			total := volumes[trade.Trader].Add(usdVolume(trade))
+			if total.Cmp(f.config.MaxTraderVolume) > 0 {
+				return true
+			}
+			volumes[trade.Trader] = total
+			return false
+		})
+	}
+
+	return accepted
+}
+
+// usdVolume returns the USD value of the swap of the trade, or its volume if unknown.
+func usdVolume(trade TradeData) Decimal {
+	if trade.USD.IsZero() {
+		return trade.Volume
+	}
+	return trade.USD
+}
+
+// reject removes the trades matching rejected, logging and counting each of them.
+func (f *TradeFilter) reject(trades []TradeData, reason RejectReason, rejected func(TradeData) bool) []TradeData {
+	kept := trades[:0]
+	for _, trade := range trades {
+		if !rejected(trade) {
+			kept = append(kept, trade)
+			continue
+		}
+
+		log.Printf("rejected trade of %s at %d by %q: %s (price %s, volume %s)\n",
+			trade.TokenName, trade.Timestamp, trade.Trader, reason, trade.Ratio, trade.Volume)
+
+		f.mu.Lock()
+		if f.rejected[trade.TokenName] == nil {
+			f.rejected[trade.TokenName] = make(map[RejectReason]int)
+		}
+		f.rejected[trade.TokenName][reason]++
+		f.mu.Unlock()
+	}
+	return kept
+}
+
+// Rejected returns the number of rejected trades per token and reason.
+func (f *TradeFilter) Rejected() map[string]map[RejectReason]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := make(map[string]map[RejectReason]int, len(f.rejected))
+	for token, reasons := range f.rejected {
+		counts[token] = make(map[RejectReason]int, len(reasons))
+		for reason, count := range reasons {
+			counts[token][reason] = count
+		}
+	}
+	return counts
+}
@@ -0,0 +1,157 @@
+package vwap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func filterTrade(trader string, side Side, volume, ratio string, ts int) TradeData {
+	return TradeData{
+		TokenName: "Token1",
+		Volume:    MustParseDecimal(volume),
+		Ratio:     MustParseDecimal(ratio),
+		Timestamp: ts,
+		Trader:    trader,
+		Side:      side,
+	}
+}
+
+func TestTradeFilterMinVolume(t *testing.T) {
+	filter := NewTradeFilter(FilterConfig{MinVolume: MustParseDecimal("10")})
+
+	accepted := filter.Filter([]TradeData{
+		filterTrade("a", SideBuy, "9.99", "1", 1),
+		filterTrade("b", SideBuy, "10", "1", 2),
+	})
+	assert.Len(t, accepted, 1)
+	assert.Equal(t, "b", accepted[0].Trader)
+	assert.Equal(t, map[string]map[RejectReason]int{"Token1": {RejectMinVolume: 1}}, filter.Rejected())
+}
+
+func TestTradeFilterSelfTrades(t *testing.T) {
+	filter := NewTradeFilter(FilterConfig{RejectSelfTrades: true})
+
+	accepted := filter.Filter([]TradeData{
+		filterTrade("wash", SideBuy, "100", "1", 1),
+		filterTrade("honest", SideBuy, "100", "1", 2),
+		filterTrade("wash", SideSell, "100", "1", 3),
+		filterTrade("", SideSell, "100", "1", 4),
+	})
+	assert.Len(t, accepted, 2)
+	assert.Equal(t, "honest", accepted[0].Trader)
+	assert.Equal(t, "", accepted[1].Trader)
+	assert.Equal(t, 2, filter.Rejected()["Token1"][RejectSelfTrade])
+}
+
+func TestTradeFilterDeviation(t *testing.T) {
+	filter := NewTradeFilter(FilterConfig{MaxDeviation: MustParseDecimal("0.1")})
+
+	// median is 2: 2.2 is at the edge of the band, 5 is a fat finger
+	accepted := filter.Filter([]TradeData{
+		filterTrade("a", SideBuy, "1", "2", 1),
+		filterTrade("b", SideBuy, "1", "2.2", 2),
+		filterTrade("c", SideBuy, "1", "5", 3),
+		filterTrade("d", SideBuy, "1", "1.9", 4),
+	})
+	assert.Len(t, accepted, 3)
+	for _, trade := range accepted {
+		assert.NotEqual(t, "c", trade.Trader)
+	}
+	assert.Equal(t, 1, filter.Rejected()["Token1"][RejectDeviation])
+}
+
+func TestTradeFilterTraderCap(t *testing.T) {
+	filter := NewTradeFilter(FilterConfig{MaxTraderVolume: MustParseDecimal("100")})
+
+	accepted := filter.Filter([]TradeData{
+		filterTrade("whale", SideBuy, "60", "1", 3),
+		filterTrade("whale", SideBuy, "60", "1", 1),
+		filterTrade("whale", SideBuy, "40", "1", 2),
+		filterTrade("other", SideBuy, "90", "1", 4),
+	})
+
+	// trades are counted in time order: 60 + 40 fills the cap
+	assert.Len(t, accepted, 3)
+	assert.Equal(t, []int{1, 2, 4}, []int{accepted[0].Timestamp, accepted[1].Timestamp, accepted[2].Timestamp})
+	assert.Equal(t, 1, filter.Rejected()["Token1"][RejectTraderCap])
+}
+
+func TestNilTradeFilterAcceptsAll(t *testing.T) {
+	var filter *TradeFilter
+	trades := []TradeData{filterTrade("a", SideBuy, "1", "1", 1)}
+	assert.Equal(t, trades, filter.Filter(trades))
+}
+
+func TestVWAPWithFilter(t *testing.T) {
+	db := newTestDB(t)
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "4", "5", time.Now()),
+		usdcSwap("0x2", "gno.land/r/demo/bar", "2", "61", time.Now()),
+	})
+	filter := NewTradeFilter(FilterConfig{MinVolume: MustParseDecimal("10")})
+
+	result, err := NewCalculator(db, source, Config{Filter: filter}).VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "30.5", result.Prices()["gno.land/r/demo/bar"][AggregatorVWAP].String())
+	assert.Equal(t, TokenSkipped, result.Tokens["gno.land/r/demo/foo"].Status)
+
+	// every trade of foo was rejected, so no price is known yet
+	assert.True(t, result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].IsZero())
+	assert.Equal(t, 1, filter.Rejected()["gno.land/r/demo/foo"][RejectMinVolume])
+
+	var row VWAPData
+	assert.NoError(t, db.Where("token_name = ?", "gno.land/r/demo/foo").First(&row).Error)
+	assert.Equal(t, StatusNoData, row.Status)
+}
+
+func TestVWAPFiltersSwapTrades(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+
+	washBuy := usdcSwap("0x1", "gno.land/r/demo/foo", "10", "100", now.Add(-3*time.Minute))
+	washBuy.TokenA, washBuy.TokenB = washBuy.TokenB, washBuy.TokenA
+	washBuy.TokenAAmount, washBuy.TokenBAmount = "100", "-10"
+	washBuy.Account = "g1wash"
+	washSell := usdcSwap("0x2", "gno.land/r/demo/foo", "10", "100", now.Add(-2*time.Minute))
+	washSell.Account = "g1wash"
+
+	source := NewMemorySource(nil, []Swap{
+		washBuy,
+		washSell,
+		usdcSwap("0x3", "gno.land/r/demo/foo", "10", "20", now.Add(-time.Minute)),
+		usdcSwap("0x4", "gno.land/r/demo/foo", "10", "30", now.Add(-time.Minute)),
+	})
+	filter := NewTradeFilter(FilterConfig{RejectSelfTrades: true, MaxTraderVolume: MustParseDecimal("25")})
+
+	result, err := NewCalculator(db, source, Config{Filter: filter}).VWAP(context.Background())
+	assert.NoError(t, err)
+	// the round trip of g1wash is rejected and g1trader is capped after the first swap
+	assert.Equal(t, "2", result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+	assert.Equal(t, 2, filter.Rejected()["gno.land/r/demo/foo"][RejectSelfTrade])
+	assert.Equal(t, 1, filter.Rejected()["gno.land/r/demo/foo"][RejectTraderCap])
+}
+
+func TestPairVWAPWithFilter(t *testing.T) {
+	db := newTestDB(t)
+
+	swaps := []Swap{
+		{Time: "2024-05-16 05:01:00", TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "10", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "-20", TotalUsd: "20", Account: "g1a"},
+		// below the minimum volume
+		{Time: "2024-05-16 05:02:00", TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "1", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "-5", TotalUsd: "5", Account: "g1b"},
+		// a round trip
+		{Time: "2024-05-16 05:03:00", TokenA: SwapToken{Symbol: "USDC"}, TokenAAmount: "90", TokenB: SwapToken{Symbol: "GNS"}, TokenBAmount: "-10", TotalUsd: "90", Account: "g1wash"},
+		{Time: "2024-05-16 05:04:00", TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "10", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "-90", TotalUsd: "90", Account: "g1wash"},
+	}
+	parsed, errs := ParseSwaps(swaps)
+	assert.Empty(t, errs)
+
+	filter := NewTradeFilter(FilterConfig{MinVolume: MustParseDecimal("10"), RejectSelfTrades: true})
+	results, err := NewCalculator(db, nil, Config{Filter: filter}).PairVWAP(context.Background(), parsed)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", results[NewPair("GNS", "USDC", 0)].String())
+	assert.Equal(t, map[RejectReason]int{RejectMinVolume: 1, RejectSelfTrade: 2}, filter.Rejected()["GNS"])
+}
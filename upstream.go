@@ -0,0 +1,283 @@
+package vwap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the API while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// errDecode marks responses that cannot be decoded, which are not retried.
+var errDecode = errors.New("failed to decode response")
+
+// ClientConfig configures a Client. Zero fields take the defaults below.
+type ClientConfig struct {
+	// Timeout bounds each attempt. Defaults to 30s.
+	Timeout time.Duration
+	// MaxRetries is the number of attempts after the first one. Defaults to 3, use -1 to disable retries.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, doubled on every retry. Defaults to 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff, including delays requested with Retry-After. Defaults to 30s.
+	MaxDelay time.Duration
+	// FailureThreshold is the number of consecutive failed requests that opens the breaker,
+	// including client errors and undecodable responses. Defaults to 5.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before a trial request is let through. Defaults to 1m.
+	Cooldown time.Duration
+}
+
+func (c ClientConfig) withDefaults() ClientConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = 500 * time.Millisecond
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = 30 * time.Second
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 5
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = time.Minute
+	}
+	return c
+}
+
+// Client calls the Gnoswap API. Failed attempts are retried with exponential backoff
+// and jitter, and a circuit breaker stops calling the API after repeated failures.
+// It is safe for concurrent use.
+type Client struct {
+	config ClientConfig
+	http   *http.Client
+
+	// replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // a trial request is in flight
+}
+
+// DefaultClient is used by the fetch functions and by sources without a client.
+// Its breaker is shared by all of them.
+var DefaultClient = NewClient(ClientConfig{})
+
+// NewClient returns a client with the given config.
+func NewClient(config ClientConfig) *Client {
+	return &Client{
+		config: config.withDefaults(),
+		http:   &http.Client{},
+		now:    time.Now,
+		sleep:  sleep,
+	}
+}
+
+// statusError is returned for non-OK responses.
+type statusError struct {
+	code       int
+	status     string
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("received non-OK status: %s", e.status)
+}
+
+// retryable reports whether a failed attempt may succeed when retried.
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+	return !errors.Is(err, errDecode)
+}
+
+// GetJSON fetches url and decodes the JSON response into v.
+// Retries stop as soon as ctx is done, without counting as a failure of the API.
+func (c *Client) GetJSON(ctx context.Context, url string, v any) error {
+	allowed, trial := c.allow()
+	if !allowed {
+		return ErrCircuitOpen
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.get(ctx, url, v)
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+		if attempt == c.config.MaxRetries {
+			break
+		}
+
+		delay := c.backoff(attempt, err)
+		log.Printf("GET %s failed (attempt %d): %v, retrying in %s\n", url, attempt+1, err, delay)
+		if err := c.sleep(ctx, delay); err != nil {
+			c.abort(trial)
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		c.abort(trial)
+		return ctx.Err()
+	}
+
+	c.record(trial, err == nil)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{
+			code:       resp.StatusCode,
+			status:     resp.Status,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), c.now()),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errDecode, err)
+	}
+	return nil
+}
+
+// backoff returns the delay before retrying the given attempt: the exponential
+// backoff with jitter, or the delay requested by the server if it is longer.
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	delay := c.config.BaseDelay << attempt
+	if delay <= 0 || delay > c.config.MaxDelay {
+		delay = c.config.MaxDelay
+	}
+	// equal jitter: half the delay is kept to preserve the backoff
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > delay {
+		delay = statusErr.retryAfter
+	}
+	return min(delay, c.config.MaxDelay)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// allow reports whether a request may be sent, and whether it is the trial request.
+// Once the cooldown has passed, a single trial request is let through: the breaker
+// closes if it succeeds and reopens if it fails, and other requests are refused meanwhile.
+func (c *Client) allow() (allowed, trial bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.open() {
+		return false, false
+	}
+	if !c.openUntil.IsZero() {
+		c.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+// open reports whether requests are refused. c.mu must be held.
+func (c *Client) open() bool {
+	return c.probing || c.now().Before(c.openUntil)
+}
+
+// abort releases the trial request if the call stopped without an answer.
+func (c *Client) abort(trial bool) {
+	if !trial {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.probing = false
+}
+
+func (c *Client) record(trial, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if trial {
+		c.probing = false
+	}
+	if ok {
+		c.failures = 0
+		c.openUntil = time.Time{}
+		return
+	}
+
+	c.failures++
+	if c.failures >= c.config.FailureThreshold {
+		c.openUntil = c.now().Add(c.config.Cooldown)
+		log.Printf("upstream failed %d times in a row, pausing requests for %s\n", c.failures, c.config.Cooldown)
+	}
+}
+
+// Open reports whether the circuit breaker is open, i.e. cooling down or waiting
+// for the answer of its trial request.
+func (c *Client) Open() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.open()
+}
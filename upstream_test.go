@@ -0,0 +1,225 @@
+package vwap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyServer fails the first failures requests with status, then serves prices.
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			for name, values := range header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"data":[{"path":"gno.land/r/demo/foo","usd":"1.5","volumeUsd24h":"10"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newTestClient returns a client that records its backoff delays instead of sleeping.
+func newTestClient(config ClientConfig, now *time.Time) (*Client, *[]time.Duration) {
+	client := NewClient(config)
+	var delays []time.Duration
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	if now != nil {
+		client.now = func() time.Time { return *now }
+	}
+	return client, &delays
+}
+
+func TestClientRetries(t *testing.T) {
+	server, requests := flakyServer(t, 2, http.StatusBadGateway, nil)
+	client, delays := newTestClient(ClientConfig{BaseDelay: 100 * time.Millisecond}, nil)
+
+	prices, err := fetchTokenPrices(context.Background(), client, server.URL)
+	assert.NoError(t, err)
+	assert.Len(t, prices, 1)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// exponential backoff with jitter of up to half the delay
+	if assert.Len(t, *delays, 2) {
+		assert.True(t, (*delays)[0] >= 50*time.Millisecond && (*delays)[0] <= 100*time.Millisecond, "got %s", (*delays)[0])
+		assert.True(t, (*delays)[1] >= 100*time.Millisecond && (*delays)[1] <= 200*time.Millisecond, "got %s", (*delays)[1])
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	server, requests := flakyServer(t, 10, http.StatusServiceUnavailable, nil)
+	client, _ := newTestClient(ClientConfig{MaxRetries: 2}, nil)
+
+	_, err := fetchTokenPrices(context.Background(), client, server.URL)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// client errors are not retried
+	server, requests = flakyServer(t, 10, http.StatusNotFound, nil)
+	_, err = fetchTokenPrices(context.Background(), client, server.URL)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClientStopsRetryingWhenCanceled(t *testing.T) {
+	server, requests := flakyServer(t, 10, http.StatusServiceUnavailable, nil)
+	client, _ := newTestClient(ClientConfig{FailureThreshold: 1}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	_, err := fetchTokenPrices(ctx, client, server.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), requests.Load())
+	// an aborted call says nothing about the API
+	assert.False(t, client.Open())
+}
+
+func TestClientHonorsRetryAfter(t *testing.T) {
+	server, _ := flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"7"}})
+	client, delays := newTestClient(ClientConfig{BaseDelay: time.Millisecond}, nil)
+
+	_, err := fetchTokenPrices(context.Background(), client, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{7 * time.Second}, *delays)
+
+	// the delay is capped
+	server, _ = flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+	client, delays = newTestClient(ClientConfig{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}, nil)
+	_, err = fetchTokenPrices(context.Background(), client, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{10 * time.Second}, *delays)
+
+	now := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	server, requests := flakyServer(t, 4, http.StatusInternalServerError, nil)
+	now := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+	client, _ := newTestClient(ClientConfig{MaxRetries: -1, FailureThreshold: 2, Cooldown: time.Minute}, &now)
+
+	for i := 0; i < 2; i++ {
+		_, err := fetchTokenPrices(context.Background(), client, server.URL)
+		assert.Error(t, err)
+	}
+	assert.True(t, client.Open())
+
+	// requests are not sent while the breaker is open
+	_, err := fetchTokenPrices(context.Background(), client, server.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, int32(2), requests.Load())
+
+	// a failed trial request after the cooldown reopens the breaker
+	now = now.Add(time.Minute)
+	_, err = fetchTokenPrices(context.Background(), client, server.URL)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrCircuitOpen))
+	assert.True(t, client.Open())
+
+	// a single trial request is let through at a time
+	now = now.Add(time.Minute)
+	allowed, trial := client.allow()
+	assert.True(t, allowed && trial)
+	allowed, _ = client.allow()
+	assert.False(t, allowed)
+	assert.True(t, client.Open())
+	client.abort(trial)
+
+	// a successful one closes it
+	requests.Store(4)
+	_, err = fetchTokenPrices(context.Background(), client, server.URL)
+	assert.NoError(t, err)
+	assert.False(t, client.Open())
+}
+
+func TestClientCountsClientErrorsAsFailures(t *testing.T) {
+	server, _ := flakyServer(t, 10, http.StatusNotFound, nil)
+	client, _ := newTestClient(ClientConfig{FailureThreshold: 2}, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := fetchTokenPrices(context.Background(), client, server.URL)
+		assert.Error(t, err)
+	}
+	assert.True(t, client.Open())
+
+	// and so are responses that cannot be decoded
+	garbage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	}))
+	defer garbage.Close()
+	client, _ = newTestClient(ClientConfig{FailureThreshold: 1}, nil)
+	_, err := fetchTokenPrices(context.Background(), client, garbage.URL)
+	assert.Error(t, err)
+	assert.True(t, client.Open())
+}
+
+func TestSourcesHaveTheirOwnClient(t *testing.T) {
+	mainnet, testnet := NewGnoswapSource(), NewGnoswapSource()
+	assert.NotNil(t, mainnet.Client)
+	assert.NotSame(t, mainnet.Client, testnet.Client)
+	assert.NotSame(t, DefaultClient, mainnet.Client)
+
+	feed := NewSwapFeed(nil, ActivitySwapEndpoint)
+	assert.NotNil(t, feed.Client)
+	assert.NotSame(t, DefaultClient, feed.Client)
+	assert.NotSame(t, mainnet.Client, feed.Client)
+}
+
+func TestVWAPCarriesForwardWhileAPIDown(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ActivitySwapResponse{Data: []Swap{usdcSwap("0x1", "gno.land/r/demo/foo", "10", "15", now)}})
+	}))
+	client, _ := newTestClient(ClientConfig{MaxRetries: -1, FailureThreshold: 1}, nil)
+	calculator := NewCalculator(db, &GnoswapSource{ActivityEndpoint: server.URL + "/v1/activity?type=%s", Client: client}, Config{})
+	calculator.now = func() time.Time { return now }
+
+	result, err := calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+
+	// the API goes down: the swaps recorded so far are still priced
+	server.Close()
+	result, err = calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+	assert.True(t, client.Open())
+
+	// and so they are while the breaker is open
+	result, err = calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+
+	// until they leave the window
+	calculator.now = func() time.Time { return now.Add(time.Hour) }
+	result, err = calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+
+	row, err := latestSeries(context.Background(), db, seriesKey{tokenName: "gno.land/r/demo/foo", aggregator: AggregatorVWAP})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCarriedForward, row.Status)
+}
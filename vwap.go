@@ -1,6 +1,10 @@
 package vwap
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -10,6 +14,9 @@ import (
 )
 
 // Token name
+//
+// Deprecated: the set of tokens is configured with a Registry. These constants
+// only seed DefaultRegistry.
 type TokenIdentifier string
 
 const (
@@ -21,100 +28,406 @@ const (
 	GNS    TokenIdentifier = "gno.land/r/demo/gns"
 )
 
+// Side tells whether a trader bought or sold the token of a trade.
+type Side string
+
+const (
+	SideBuy  Side = "buy"
+	SideSell Side = "sell"
+)
+
 // TradeData represents the data for a single trade.
+// Pair is only set for trades of a pair, whose Ratio is then quoted in the pair's quote token.
+// Trader, Side and USD are only known for trades built from swaps.
 type TradeData struct {
 	TokenName string
-	Volume    float64
-	Ratio     float64
+	Pair      Pair
+	Volume    Decimal
+	Ratio     Decimal
 	Timestamp int
+	Trader    string
+	Side      Side
+	// USD is the TotalUsd of the swap of the trade.
+	USD Decimal
 }
 
-// lastPrices stores the last price of each token.
-// This value will be used to show the last price if the token is not traded.
-var (
-	lastPrices      map[string]float64
-	lastPricesMutex sync.Mutex
-)
+// Config selects how VWAP processes trades. The zero value computes the VWAP
+// of unfiltered trades.
+type Config struct {
+	// Aggregators to compute and store. Defaults to VWAPAggregator.
+	Aggregators []Aggregator
+	// Filter, if set, drops suspicious trades before aggregation.
+	Filter *TradeFilter
+	// Registry, if set, restricts the calculation to its enabled tokens.
+	Registry *Registry
+	// Window is the rolling window of the swaps priced by VWAP, and the bucket of the
+	// stored rows, which holds a single row per series. It should match the
+	// calculation interval. Defaults to Window10m.
+	Window time.Duration
+}
+
+// seriesKey identifies a series of stored prices: a token, or a pair if pair is set,
+// aggregated with a given aggregator.
+type seriesKey struct {
+	tokenName  string
+	pair       string
+	aggregator string
+}
+
+func seriesKeyOf(row VWAPData) seriesKey {
+	return seriesKey{tokenName: row.TokenName, pair: row.Pair, aggregator: row.Aggregator}
+}
+
+// Calculator calculates prices from a source and stores them in a database.
+// Each calculator keeps its own last prices, so that independent pipelines,
+// e.g. for mainnet and testnet, can run in one process.
+type Calculator struct {
+	db     *gorm.DB
+	source TradeSource
+	config Config
+
+	// lastPrices stores the last price of each series.
+	// This value will be used to show the last price if the token is not traded.
+	mu         sync.Mutex
+	lastPrices map[seriesKey]Decimal
+
+	// swaps holds the trades of the recorded swaps within the window.
+	// seenSwaps, guarded by mu, tells the recorded swaps apart by swapID.
+	swaps     *WindowedVWAP
+	seenSwaps map[string]time.Time
 
-func init() {
-	lastPrices = make(map[string]float64)
+	now func() time.Time
 }
 
-func VWAP(db *gorm.DB) (map[string]float64, error) {
-	if db == nil {
-		return nil, fmt.Errorf("db is nil")
+// NewCalculator returns a calculator storing into db the prices of the tokens
+// provided by source. The source may be nil if only PairVWAP is used.
+func NewCalculator(db *gorm.DB, source TradeSource, config Config) *Calculator {
+	return &Calculator{
+		db:         db,
+		source:     source,
+		config:     config,
+		lastPrices: make(map[seriesKey]Decimal),
+		swaps:      NewWindowedVWAP(windowOrDefault(config.Window)),
+		seenSwaps:  make(map[string]time.Time),
+		now:        time.Now,
 	}
-	prices, err := fetchTokenPrices(priceEndpoint)
-	if err != nil {
-		return nil, err
+}
+
+func (c *Calculator) window() time.Duration {
+	return windowOrDefault(c.config.Window)
+}
+
+func windowOrDefault(window time.Duration) time.Duration {
+	if window <= 0 {
+		return Window10m
+	}
+	return window
+}
+
+// AddSwaps records swaps to be priced by VWAP, e.g. the swaps read from a SwapFeed.
+// Swaps that were already recorded are ignored, and so are the trades of tokens
+// disabled by the registry. It returns the number of swaps recorded.
+func (c *Calculator) AddSwaps(swaps []ParsedSwap) int {
+	registry := c.config.Registry
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	added := 0
+	for _, swap := range swaps {
+		id := swapID(swap)
+		if _, ok := c.seenSwaps[id]; ok {
+			continue
+		}
+		c.seenSwaps[id] = swap.Time
+
+		var trades []TradeData
+		for _, trade := range swapToTrades(swap) {
+			if registry == nil || registry.Enabled(trade.TokenName) || registry.EnabledSymbol(trade.TokenName) {
+				trades = append(trades, trade)
+			}
+		}
+		if len(trades) > 0 {
+			c.swaps.Add(trades...)
+			added++
+		}
 	}
+	return added
+}
 
-	volumeByToken := calculateVolume(prices)
-	trades := extractTrades(prices, volumeByToken)
-	vwapResults := make(map[string]float64)
+// forgetSwaps drops the recorded swaps that can no longer enter the window ending at now.
+func (c *Calculator) forgetSwaps(now time.Time) {
+	from := now.Add(-c.window())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, at := range c.seenSwaps {
+		if !at.After(from) {
+			delete(c.seenSwaps, id)
+		}
+	}
+}
+
+// swapID identifies a swap by its tx hash, or by its content if it has none.
+func swapID(swap ParsedSwap) string {
+	if swap.TxHash != "" {
+		return swap.TxHash
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s", swap.Time.UTC().Format(time.RFC3339Nano), swap.Account,
+		swap.TokenA.ID(), swap.AmountA, swap.TokenB.ID(), swap.AmountB, swap.Direction)
+}
+
+// VWAP calculates and stores the price of every token with each of the configured
+// aggregators, from the trades of the swaps within the window ending now. The swaps of
+// the source are recorded first, see AddSwaps. Tokens without trades in the window, but
+// with a last known price, carry that price forward and are reported as skipped.
+//
+// The result holds the status of every token: tokens that fail are reported there
+// rather than dropped, and Result.Err tells whether any did. The tokens of swaps
+// that cannot be parsed fail with ErrParse, even if their other swaps are priced.
+// The returned error is only set when the whole run failed.
+// The prices of the run are stored in a single transaction with the run ID of the
+// result: if storing fails, no price is stored and every priced token fails with ErrStorage.
+// If the swaps cannot be fetched, e.g. while the API is down, the swaps recorded so
+// far are priced, so that every tick is stored.
+// If ctx is done before every price is stored, VWAP returns ctx.Err().
+func (c *Calculator) VWAP(ctx context.Context) (Result, error) {
+	if c.db == nil {
+		return Result{}, fmt.Errorf("db is nil")
+	}
+	if c.source == nil {
+		return Result{}, fmt.Errorf("source is nil")
+	}
+	config := c.config
+	aggregators := config.Aggregators
+	if len(aggregators) == 0 {
+		aggregators = []Aggregator{VWAPAggregator{}}
+	}
+
+	result := newResult()
+
+	swaps, err := c.source.Swaps(ctx)
+	switch {
+	case ctx.Err() != nil:
+		return Result{}, ctx.Err()
+	case err != nil:
+		log.Printf("failed to fetch swaps, pricing the swaps recorded so far: %v\n", err)
+	default:
+		parsed, errs := ParseSwaps(swaps)
+		c.AddSwaps(parsed)
+		c.failMalformedSwaps(result, errs)
+	}
+
+	now := c.now()
+	c.forgetSwaps(now)
+	trades := c.swaps.tradesByToken(now)
+	for tokenName := range c.lastPricedTokens() {
+		if _, ok := trades[tokenName]; !ok {
+			trades[tokenName] = nil
+		}
+	}
+
+	if config.Registry != nil {
+		for tokenName := range trades {
+			if !config.Registry.Enabled(tokenName) && !config.Registry.EnabledSymbol(tokenName) {
+				delete(trades, tokenName)
+			}
+		}
+		// every enabled token gets a row, even if it was never traded
+		for _, token := range config.Registry.Tokens() {
+			_, byPath := trades[token.Path]
+			_, bySymbol := trades[token.Symbol]
+			if token.Enabled && !byPath && !bySymbol {
+				trades[token.Path] = nil
+			}
+		}
+	}
 
 	var (
 		wg    sync.WaitGroup
 		mutex sync.Mutex
+		rows  []VWAPData
 	)
 
 	for tokenName, tradeData := range trades {
-		wg.Add(1)
-		go func(tokenName string, tradeData []TradeData) {
-			defer wg.Done()
-			res, err := calculateVWAP(db, tradeData)
-			if err != nil {
-				log.Printf("failed to calculate VWAP for token %s: %v\n", tokenName, err)
-				return
-			}
-			mutex.Lock()
-			vwapResults[tokenName] = res
-			mutex.Unlock()
-		}(tokenName, tradeData)
+		tradeData = config.Filter.Filter(tradeData)
+		for _, aggregator := range aggregators {
+			wg.Add(1)
+			go func(tokenName string, tradeData []TradeData, aggregator Aggregator) {
+				defer wg.Done()
+				if ctx.Err() != nil {
+					return
+				}
+				key := seriesKey{tokenName: tokenName, aggregator: aggregator.Name()}
+				row, err := c.price(ctx, c.db, key, tradeData, aggregatorAt(aggregator, now), now)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					result.fail(tokenName, fmt.Errorf("failed to calculate %s for token %s: %w", aggregator.Name(), tokenName, err))
+					return
+				}
+				rows = append(rows, row)
+			}(tokenName, tradeData, aggregator)
+		}
 	}
 
 	wg.Wait()
 
-	return vwapResults, nil
-}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
 
-// calculateVWAP calculates the Volume Weighted Average Price (calculateVWAP) for the given set of trades.
-// It returns the last price if there are no trades.
-func calculateVWAP(db *gorm.DB, trades []TradeData) (float64, error) {
-	var numerator, denominator float64
+	result.RunID = newRunID()
+	if err := c.commit(ctx, c.db, result.RunID, rows); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{}, ctxErr
+		}
+		for _, row := range rows {
+			result.fail(row.TokenName, fmt.Errorf("failed to store %s for token %s: %w", row.Aggregator, row.TokenName, err))
+		}
+	} else {
+		for _, row := range rows {
+			result.succeed(row.TokenName, row.Aggregator, row.VWAP)
+			if row.Status != StatusComputed {
+				result.fail(row.TokenName, fmt.Errorf("no trades of token %s within %s: %w", row.TokenName, c.window(), ErrMissingVolume))
+			}
+		}
+	}
 
-	if len(trades) == 0 {
-		return 0, fmt.Errorf("no trades found")
+	for _, res := range result.Tokens {
+		if res.Err != nil {
+			log.Printf("%s: %v\n", res.Status, res.Err)
+		}
 	}
 
+	return result, nil
+}
+
+// failMalformedSwaps reports the errors of ParseSwaps against the tokens of the
+// malformed swaps, wrapping ErrParse.
+func (c *Calculator) failMalformedSwaps(result Result, errs []error) {
+	registry := c.config.Registry
+	for _, err := range errs {
+		var parseErr *SwapParseError
+		if !errors.As(err, &parseErr) {
+			continue
+		}
+		for _, tokenName := range []string{parseErr.TokenA, parseErr.TokenB} {
+			if tokenName == "" || (registry != nil && !registry.Enabled(tokenName) && !registry.EnabledSymbol(tokenName)) {
+				continue
+			}
+			result.fail(tokenName, fmt.Errorf("failed to parse swap of token %s: %w: %v", tokenName, ErrParse, err))
+		}
+	}
+}
+
+// price aggregates the trades of a series into the row to store for the bucket of at.
+// It returns the last price of the series if no trade has volume, e.g. when
+// every trade was rejected by the filter.
+func (c *Calculator) price(ctx context.Context, db *gorm.DB, key seriesKey, trades []TradeData, aggregator Aggregator, at time.Time) (VWAPData, error) {
+	var totalVolume Decimal
 	for _, trade := range trades {
-		numerator += trade.Volume * trade.Ratio
-		denominator += trade.Volume
+		totalVolume = totalVolume.Add(trade.Volume)
 	}
 
+	status := StatusComputed
+	price, ok := aggregator.Aggregate(trades)
+
 	// return last price if there is no trade
-	if denominator == 0 {
-		lastPricesMutex.Lock()
-		lastPrice, ok := lastPrices[trades[0].TokenName]
-		lastPricesMutex.Unlock()
+	if !ok {
+		lastPrice, ok, err := c.lastPrice(ctx, db, key)
+		if err != nil {
+			return VWAPData{}, fmt.Errorf("%w: %v", ErrStorage, err)
+		}
+
+		price, status = lastPrice, StatusCarriedForward
 		if !ok {
-			return 0, nil
+			status = StatusNoData
 		}
-		return lastPrice, nil
 	}
 
-	vwap := numerator / denominator
-	lastPricesMutex.Lock()
-	lastPrices[trades[0].TokenName] = vwap // save the last price
-	lastPricesMutex.Unlock()
+	return newVWAPData(key, c.window(), price, totalVolume, at, status), nil
+}
 
-	totalVolume := denominator
-	calculatedAt := time.Now()
+// commit stores the rows of a run, then saves the computed prices as the last prices.
+// Nothing is stored nor saved if storing any row fails.
+func (c *Calculator) commit(ctx context.Context, db *gorm.DB, runID string, rows []VWAPData) error {
+	if err := storeRun(ctx, db, runID, rows); err != nil {
+		return fmt.Errorf("%w: failed to store data: %v", ErrStorage, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, row := range rows {
+		if row.Status == StatusComputed {
+			c.lastPrices[seriesKeyOf(row)] = row.VWAP // save the last price
+		}
+	}
+	return nil
+}
+
+// newRunID returns a random identifier for the rows stored by a run.
+func newRunID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate run ID: %v", err))
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// RestoreLastPrices seeds the last price of every series from the latest stored row,
+// so that the fallback for tokens without volume survives restarts.
+func (c *Calculator) RestoreLastPrices(ctx context.Context) error {
+	rows, err := latestVWAPs(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, row := range rows {
+		c.lastPrices[seriesKeyOf(row)] = row.VWAP
+	}
+
+	return nil
+}
+
+// lastPricedTokens returns every token with a last known price, without trades.
+func (c *Calculator) lastPricedTokens() map[string][]TradeData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens := make(map[string][]TradeData)
+	for key := range c.lastPrices {
+		if key.pair == "" {
+			tokens[key.tokenName] = nil
+		}
+	}
+	return tokens
+}
+
+// lastPrice returns the last known price of the series.
+// It falls back to the latest stored row when the price is not cached yet.
+func (c *Calculator) lastPrice(ctx context.Context, db *gorm.DB, key seriesKey) (Decimal, bool, error) {
+	c.mu.Lock()
+	price, ok := c.lastPrices[key]
+	c.mu.Unlock()
+	if ok {
+		return price, true, nil
+	}
 
-	err := store(db, trades[0].TokenName, vwap, totalVolume, calculatedAt)
+	row, err := latestSeries(ctx, db, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Decimal{}, false, nil
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to store data: %v", err)
+		return Decimal{}, false, fmt.Errorf("failed to load last price: %v", err)
 	}
 
-	return vwap, nil
+	c.mu.Lock()
+	c.lastPrices[key] = row.VWAP
+	c.mu.Unlock()
+
+	return row.VWAP, true, nil
 }
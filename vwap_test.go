@@ -0,0 +1,263 @@
+package vwap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a migrated in-memory database. The pool is limited to a single
+// connection since every new SQLite connection would get its own empty database.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if _, err := Migrate(context.Background(), db); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return db
+}
+
+// usdcSwap returns a swap of amount of the token for usd USDC.
+func usdcSwap(hash, token, amount, usd string, at time.Time) Swap {
+	return Swap{
+		TxHash:       hash,
+		Time:         at.Format(time.RFC3339),
+		TokenA:       SwapToken{Path: token},
+		TokenAAmount: amount,
+		TokenB:       SwapToken{Path: "gno.land/r/demo/usdc"},
+		TokenBAmount: "-" + usd,
+		TotalUsd:     usd,
+		Account:      "g1trader",
+	}
+}
+
+func TestVWAPWithMemorySource(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", now.Add(-5*time.Minute)),
+		usdcSwap("0x2", "gno.land/r/demo/foo", "10", "20", now.Add(-time.Minute)),
+		usdcSwap("0x3", "gno.land/r/demo/bar", "2", "61", now.Add(-time.Minute)),
+		// out of the window
+		usdcSwap("0x4", "gno.land/r/demo/foo", "1", "1000", now.Add(-time.Hour)),
+	})
+
+	result, err := NewCalculator(db, source, Config{}).VWAP(context.Background())
+	assert.NoError(t, err)
+	// (10*1.25 + 20*2) / 30
+	assert.Equal(t, "1.75", result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+	assert.Equal(t, "30.5", result.Prices()["gno.land/r/demo/bar"][AggregatorVWAP].String())
+	assert.Equal(t, "1", result.Prices()["gno.land/r/demo/usdc"][AggregatorVWAP].String())
+
+	var count int64
+	db.Model(&VWAPData{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestVWAPRecordsEachSwapOnce(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	source := NewMemorySource(nil, []Swap{usdcSwap("0x1", "gno.land/r/demo/foo", "10", "20", base.Add(-time.Minute))})
+	calculator := NewCalculator(db, source, Config{})
+	calculator.now = func() time.Time { return base }
+
+	_, err := calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+
+	// the source serves the same swap again, with a new one
+	source.SetSwaps([]Swap{
+		usdcSwap("0x2", "gno.land/r/demo/foo", "10", "40", base.Add(8*time.Minute)),
+		usdcSwap("0x1", "gno.land/r/demo/foo", "10", "20", base.Add(-time.Minute)),
+	})
+	calculator.now = func() time.Time { return base.Add(8 * time.Minute) }
+
+	result, err := calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	// (20*2 + 40*4) / 60
+	price := result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP]
+	assert.Equal(t, NewDecimalFromInt(200).Quo(NewDecimalFromInt(60)).String(), price.String())
+
+	// once the window has passed the swaps, the last price is carried forward
+	source.SetSwaps(nil)
+	calculator.now = func() time.Time { return base.Add(30 * time.Minute) }
+
+	result, err = calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, TokenSkipped, result.Tokens["gno.land/r/demo/foo"].Status)
+	assert.Equal(t, price.String(), result.Prices()["gno.land/r/demo/foo"][AggregatorVWAP].String())
+	assert.NoError(t, result.Err())
+}
+
+func TestVWAPRequiresDBAndSource(t *testing.T) {
+	_, err := NewCalculator(nil, NewMemorySource(nil, nil), Config{}).VWAP(context.Background())
+	assert.Error(t, err)
+
+	_, err = NewCalculator(newTestDB(t), nil, Config{}).VWAP(context.Background())
+	assert.Error(t, err)
+}
+
+func TestVWAPCanceled(t *testing.T) {
+	db := newTestDB(t)
+	source := NewMemorySource(nil, []Swap{usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now())})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewCalculator(db, source, Config{}).VWAP(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var count int64
+	db.Model(&VWAPData{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRestoreLastPrices(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 16, 5, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "Restored", aggregator: AggregatorVWAP}, Window10m, MustParseDecimal("1.5"), MustParseDecimal("10"), base, StatusComputed)}))
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "Restored", aggregator: AggregatorVWAP}, Window10m, MustParseDecimal("1.75"), MustParseDecimal("10"), base.Add(10*time.Minute), StatusComputed)}))
+
+	// simulate a restart
+	calculator := NewCalculator(db, nil, Config{})
+	assert.NoError(t, calculator.RestoreLastPrices(context.Background()))
+
+	calculator.mu.Lock()
+	restored := calculator.lastPrices[seriesKey{tokenName: "Restored", aggregator: AggregatorVWAP}]
+	calculator.mu.Unlock()
+	assert.Equal(t, "1.75", restored.String())
+
+	price, err := priceTick(context.Background(), calculator, []TradeData{{TokenName: "Restored", Timestamp: int(base.Unix())}})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.75", price.String())
+}
+
+func TestCalculateVWAPFallsBackToStoredPrice(t *testing.T) {
+	db := newTestDB(t)
+	calculator := NewCalculator(db, nil, Config{})
+
+	assert.NoError(t, storeRun(context.Background(), db, "seed", []VWAPData{newVWAPData(seriesKey{tokenName: "Stored", aggregator: AggregatorVWAP}, Window10m, MustParseDecimal("2.25"), MustParseDecimal("10"), time.Now(), StatusComputed)}))
+
+	price, err := priceTick(context.Background(), calculator, []TradeData{{TokenName: "Stored"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.25", price.String())
+
+	price, err = priceTick(context.Background(), calculator, []TradeData{{TokenName: "Unknown"}})
+	assert.NoError(t, err)
+	assert.True(t, price.IsZero())
+}
+
+func TestVWAPWithAggregators(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2024, 5, 16, 5, 10, 0, 0, time.UTC)
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "10", "10", now.Add(-8*time.Minute)),
+		usdcSwap("0x2", "gno.land/r/demo/foo", "10", "30", now.Add(-6*time.Minute)),
+		usdcSwap("0x3", "gno.land/r/demo/foo", "10", "20", now.Add(-2*time.Minute)),
+	})
+
+	calculator := NewCalculator(db, source, Config{Aggregators: []Aggregator{VWAPAggregator{}, TWAPAggregator{}, MedianAggregator{}}})
+	calculator.now = func() time.Time { return now }
+	result, err := calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+
+	prices := result.Prices()["gno.land/r/demo/foo"]
+	assert.Len(t, prices, 3)
+	// (10*1 + 30*3 + 20*2) / 60
+	assert.Equal(t, NewDecimalFromInt(140).Quo(NewDecimalFromInt(60)).String(), prices[AggregatorVWAP].String())
+	// 1 for 2 minutes, 3 for 4 minutes and 2 for 2 minutes until now
+	assert.Equal(t, "2.25", prices[AggregatorTWAP].String())
+	assert.Equal(t, "2", prices[AggregatorMedian].String())
+
+	var rows []VWAPData
+	db.Where("token_name = ?", "gno.land/r/demo/foo").Order("aggregator").Find(&rows)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, AggregatorMedian, rows[0].Aggregator)
+	assert.Equal(t, AggregatorTWAP, rows[1].Aggregator)
+	assert.Equal(t, AggregatorVWAP, rows[2].Aggregator)
+}
+
+func TestCalculatorsAreIsolated(t *testing.T) {
+	mainnet := NewCalculator(newTestDB(t), nil, Config{})
+	testnet := NewCalculator(newTestDB(t), nil, Config{})
+
+	_, err := priceTick(context.Background(), mainnet, []TradeData{{TokenName: "GNS", Volume: MustParseDecimal("10"), Ratio: MustParseDecimal("2")}})
+	assert.NoError(t, err)
+
+	price, err := priceTick(context.Background(), mainnet, []TradeData{{TokenName: "GNS"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", price.String())
+
+	// the price of the other calculator does not leak
+	price, err = priceTick(context.Background(), testnet, []TradeData{{TokenName: "GNS"}})
+	assert.NoError(t, err)
+	assert.True(t, price.IsZero())
+}
+
+func TestVWAPStoresRunInOneTransaction(t *testing.T) {
+	db := newTestDB(t)
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now()),
+		usdcSwap("0x2", "gno.land/r/demo/bar", "2", "61", time.Now()),
+	})
+	config := Config{Aggregators: []Aggregator{VWAPAggregator{}, MedianAggregator{}}}
+
+	result, err := NewCalculator(db, source, config).VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.RunID)
+
+	var rows []VWAPData
+	db.Find(&rows)
+	// foo, bar and usdc
+	assert.Len(t, rows, 6)
+	for _, row := range rows {
+		assert.Equal(t, result.RunID, row.RunID)
+	}
+}
+
+func TestVWAPStoresNothingIfAnyRowFails(t *testing.T) {
+	db := newTestDB(t)
+	assert.NoError(t, db.Exec(`CREATE TRIGGER reject_bar BEFORE INSERT ON vwap_data
+		WHEN NEW.token_name = 'gno.land/r/demo/bar'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END`).Error)
+
+	source := NewMemorySource(nil, []Swap{
+		usdcSwap("0x1", "gno.land/r/demo/foo", "8", "10", time.Now()),
+		usdcSwap("0x2", "gno.land/r/demo/bar", "2", "61", time.Now()),
+	})
+	calculator := NewCalculator(db, source, Config{})
+
+	result, err := calculator.VWAP(context.Background())
+	assert.NoError(t, err)
+	assert.ErrorIs(t, result.Err(), ErrStorage)
+	assert.Equal(t, 3, result.Count(TokenStorageError))
+	assert.Empty(t, result.Prices())
+
+	var count int64
+	db.Model(&VWAPData{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// the prices of the failed run are not used as last prices
+	calculator.mu.Lock()
+	assert.Empty(t, calculator.lastPrices)
+	calculator.mu.Unlock()
+}
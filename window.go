@@ -0,0 +1,185 @@
+package vwap
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Rolling windows commonly used for VWAP.
+const (
+	Window10m = 10 * time.Minute
+	Window1h  = time.Hour
+	Window24h = 24 * time.Hour
+)
+
+// WindowedVWAP computes per-token VWAP over a rolling time window
+// using individual swaps rather than 24h snapshot volume.
+type WindowedVWAP struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	trades map[string][]TradeData
+}
+
+func NewWindowedVWAP(window time.Duration) *WindowedVWAP {
+	return &WindowedVWAP{
+		window: window,
+		trades: make(map[string][]TradeData),
+	}
+}
+
+// Window returns the length of the rolling window.
+func (w *WindowedVWAP) Window() time.Duration {
+	return w.window
+}
+
+// Add records trades in the engine. Trades may be added in any order.
+func (w *WindowedVWAP) Add(trades ...TradeData) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, trade := range trades {
+		w.trades[trade.TokenName] = append(w.trades[trade.TokenName], trade)
+	}
+}
+
+// AddSwaps parses each swap, converts it into trades and records them.
+// Malformed swaps are logged and skipped. It returns the number of trades added.
+func (w *WindowedVWAP) AddSwaps(swaps []Swap) int {
+	return w.AddParsedSwaps(parseSwapsLogged(swaps))
+}
+
+// AddParsedSwaps converts each swap into trades and records them.
+// It returns the number of trades added.
+func (w *WindowedVWAP) AddParsedSwaps(swaps []ParsedSwap) int {
+	added := 0
+	for _, swap := range swaps {
+		trades := swapToTrades(swap)
+		w.Add(trades...)
+		added += len(trades)
+	}
+	return added
+}
+
+// Trades returns the trades of the token that fall within the window ending at now,
+// ordered by timestamp.
+func (w *WindowedVWAP) Trades(tokenName string, now time.Time) []TradeData {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.inWindow(w.trades[tokenName], now)
+}
+
+// Calculate returns the VWAP of every token that traded within the window ending at now.
+// Trades older than the window are discarded.
+func (w *WindowedVWAP) Calculate(now time.Time) map[string]Decimal {
+	results := make(map[string]Decimal)
+	for tokenName, trades := range w.tradesByToken(now) {
+		vwap, volume := weightedAverage(trades)
+		if volume.IsZero() {
+			continue
+		}
+		results[tokenName] = vwap
+	}
+
+	return results
+}
+
+// tradesByToken returns the trades of every token that traded within the window
+// ending at now, ordered by timestamp. Trades older than the window are discarded.
+func (w *WindowedVWAP) tradesByToken(now time.Time) map[string][]TradeData {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	trades := make(map[string][]TradeData)
+	for tokenName := range w.trades {
+		// prune compacts the trades in place, so read them back afterwards
+		w.prune(tokenName, now)
+
+		if selected := w.inWindow(w.trades[tokenName], now); len(selected) > 0 {
+			trades[tokenName] = selected
+		}
+	}
+
+	return trades
+}
+
+// inWindow returns the trades within (now - window, now].
+func (w *WindowedVWAP) inWindow(trades []TradeData, now time.Time) []TradeData {
+	from := now.Add(-w.window).Unix()
+	to := now.Unix()
+
+	selected := make([]TradeData, 0, len(trades))
+	for _, trade := range trades {
+		ts := int64(trade.Timestamp)
+		if ts > from && ts <= to {
+			selected = append(selected, trade)
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Timestamp < selected[j].Timestamp
+	})
+
+	return selected
+}
+
+// prune drops the trades of the token that can no longer enter the window.
+func (w *WindowedVWAP) prune(tokenName string, now time.Time) {
+	from := now.Add(-w.window).Unix()
+
+	kept := w.trades[tokenName][:0]
+	for _, trade := range w.trades[tokenName] {
+		if int64(trade.Timestamp) > from {
+			kept = append(kept, trade)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(w.trades, tokenName)
+		return
+	}
+	w.trades[tokenName] = kept
+}
+
+// weightedAverage returns the volume weighted average ratio and the total volume of the trades.
+func weightedAverage(trades []TradeData) (Decimal, Decimal) {
+	var numerator, denominator Decimal
+
+	for _, trade := range trades {
+		numerator = numerator.Add(trade.Volume.Mul(trade.Ratio))
+		denominator = denominator.Add(trade.Volume)
+	}
+
+	if denominator.IsZero() {
+		return Decimal{}, Decimal{}
+	}
+
+	return numerator.Quo(denominator), denominator
+}
+
+// swapToTrades turns a swap into one trade per token side.
+// The USD value of the swap is used as volume and the USD price of each token
+// is derived from its traded amount.
+func swapToTrades(swap ParsedSwap) []TradeData {
+	tokenIn, amountIn := swap.TokenIn()
+	tokenOut, amountOut := swap.TokenOut()
+
+	trade := func(token SwapToken, amount Decimal, side Side) TradeData {
+		return TradeData{
+			TokenName: token.ID(),
+			Volume:    swap.TotalUsd,
+			Ratio:     swap.TotalUsd.Quo(amount),
+			Timestamp: int(swap.Time.Unix()),
+			Trader:    swap.Account,
+			Side:      side,
+			USD:       swap.TotalUsd,
+		}
+	}
+
+	return []TradeData{
+		trade(tokenIn, amountIn, SideSell),
+		trade(tokenOut, amountOut, SideBuy),
+	}
+}
@@ -0,0 +1,105 @@
+package vwap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwapToTrades(t *testing.T) {
+	swap := Swap{
+		Time:         "2024-05-16T05:21:17Z",
+		TokenA:       SwapToken{Symbol: "GNS"},
+		TokenAAmount: "100",
+		TokenB:       SwapToken{Symbol: "GNOT"},
+		TokenBAmount: "-50",
+		TotalUsd:     "200",
+		Account:      "g1trader",
+	}
+
+	parsed, err := ParseSwap(swap)
+	assert.NoError(t, err)
+	trades := swapToTrades(parsed)
+	assert.Len(t, trades, 2)
+
+	ts := time.Date(2024, 5, 16, 5, 21, 17, 0, time.UTC).Unix()
+	assert.Equal(t, "GNS", trades[0].TokenName)
+	assert.Equal(t, "200", trades[0].Volume.String())
+	assert.Equal(t, "2", trades[0].Ratio.String())
+	assert.Equal(t, int(ts), trades[0].Timestamp)
+	assert.Equal(t, "g1trader", trades[0].Trader)
+	assert.Equal(t, SideSell, trades[0].Side)
+	assert.Equal(t, "GNOT", trades[1].TokenName)
+	assert.Equal(t, "4", trades[1].Ratio.String())
+	assert.Equal(t, SideBuy, trades[1].Side)
+}
+
+func TestWindowedVWAP(t *testing.T) {
+	now := time.Date(2024, 5, 16, 6, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) string {
+		return now.Add(-ago).Format(time.RFC3339)
+	}
+
+	swaps := []Swap{
+		{Time: at(2 * time.Minute), TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "100", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "100", TotalUsd: "100"},
+		{Time: at(5 * time.Minute), TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "150", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "300", TotalUsd: "300"},
+		{Time: at(30 * time.Minute), TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "100", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "500", TotalUsd: "500"},
+		{Time: at(2 * time.Hour), TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "1", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "1000", TotalUsd: "1000"},
+		{Time: "invalid", TokenA: SwapToken{Symbol: "GNS"}, TokenAAmount: "1", TokenB: SwapToken{Symbol: "USDC"}, TokenBAmount: "1", TotalUsd: "1"},
+	}
+
+	tests := []struct {
+		window   time.Duration
+		expected Decimal
+	}{
+		// (100*1 + 300*2) / 400
+		{Window10m, MustParseDecimal("1.75")},
+		// (100*1 + 300*2 + 500*5) / 900
+		{Window1h, NewDecimalFromInt(3200).Quo(NewDecimalFromInt(900))},
+		// (100*1 + 300*2 + 500*5 + 1000*1000) / 1900
+		{Window24h, NewDecimalFromInt(1003200).Quo(NewDecimalFromInt(1900))},
+	}
+
+	for _, tt := range tests {
+		engine := NewWindowedVWAP(tt.window)
+		assert.Equal(t, 8, engine.AddSwaps(swaps))
+
+		results := engine.Calculate(now)
+		assert.True(t, tt.expected.Equal(results["GNS"]), "window %s: got %s", tt.window, results["GNS"])
+		assert.Equal(t, "1", results["USDC"].String(), "window %s", tt.window)
+	}
+}
+
+func TestWindowedVWAPPrunesExpiredTrades(t *testing.T) {
+	now := time.Date(2024, 5, 16, 6, 0, 0, 0, time.UTC)
+
+	engine := NewWindowedVWAP(Window10m)
+	engine.Add(
+		TradeData{TokenName: "GNS", Volume: NewDecimalFromInt(10), Ratio: NewDecimalFromInt(1), Timestamp: int(now.Add(-15 * time.Minute).Unix())},
+		TradeData{TokenName: "GNS", Volume: NewDecimalFromInt(10), Ratio: NewDecimalFromInt(3), Timestamp: int(now.Add(-5 * time.Minute).Unix())},
+	)
+
+	assert.Len(t, engine.Trades("GNS", now), 1)
+	assert.Equal(t, "3", engine.Calculate(now)["GNS"].String())
+
+	// no trades remain once the window has moved past them
+	results := engine.Calculate(now.Add(20 * time.Minute))
+	assert.NotContains(t, results, "GNS")
+	assert.Empty(t, engine.Trades("GNS", now))
+}
+
+func TestWindowedVWAPDoesNotCountKeptTradesTwice(t *testing.T) {
+	now := time.Date(2024, 5, 16, 6, 0, 0, 0, time.UTC)
+
+	engine := NewWindowedVWAP(Window10m)
+	engine.Add(
+		TradeData{TokenName: "GNS", Volume: NewDecimalFromInt(100), Ratio: NewDecimalFromInt(1), Timestamp: int(now.Add(-time.Hour).Unix())},
+		TradeData{TokenName: "GNS", Volume: NewDecimalFromInt(1), Ratio: NewDecimalFromInt(1), Timestamp: int(now.Add(-time.Minute).Unix())},
+		TradeData{TokenName: "GNS", Volume: NewDecimalFromInt(1), Ratio: NewDecimalFromInt(3), Timestamp: int(now.Unix())},
+	)
+
+	// the oldest trade falls out of the window: (1*1 + 1*3) / 2
+	assert.Equal(t, "2", engine.Calculate(now)["GNS"].String())
+	assert.Len(t, engine.Trades("GNS", now), 2)
+}